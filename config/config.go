@@ -1,11 +1,176 @@
 package config
 
-// JWT constants
-const HmacSampleSecret = "(;.W2g]z[eN@Eck["
-const JWTExpirationTimeInMinutes = 15
-const JWTRefreshDeadlineInHours = 7 * 24
+// Config holds the settings of the server
+// Every field can be set, from the lowest to the highest priority, by the YAML config file (key named as the field),
+// by an environment variable (GOAPI_ followed by the field name in upper snake case, e.g. GOAPI_DATABASE_URI)
+// and by a command line flag (field name in kebab case, e.g. -database-uri)
+// Lists are comma separated in the environment variables and the flags
+type Config struct {
+	// Application status
+	DevStatus                bool // must be false for production
+	Port                     int
+	ReadTimeoutInSeconds     int    // also closes the idle keep-alive connections, so that they do not block the shutdown
+	ShutdownTimeoutInSeconds int    // maximum wait for the in-flight requests on SIGINT or SIGTERM
+	MetricsEnabled           bool   // exposes the Prometheus metrics on /metrics, off by default as the endpoint has no authentication
+	LogLevel                 string // lowest level written in the JSON logs: debug, info, warn or error
+
+	// Tracing
+	// The spans of each request are sent to an OpenTelemetry collector with the "otlp" exporter,
+	// with "none" the traceparent headers are still propagated but no span is recorded
+	TracingExporter     string
+	TracingOTLPEndpoint string // base URL of the collector, the spans are sent to /v1/traces
+	TracingServiceName  string
+	TracingQueueSize    int // spans waiting for the export, the new ones are dropped when it is full
+
+	// Database
+	DatabaseURI                 string
+	DatabaseName                string
+	DatabaseSetupRetryInSeconds int // indexes and migrations are retried until they succeed, the server is not ready before
+	HealthPingTimeoutInSeconds  int // the readiness probe fails if MongoDB does not answer in time
+	// Deadline of each operation of the user and house repositories, a request fails with 504 when it is reached
+	DatabaseOperationTimeoutInMilliseconds int
+
+	// JWT
+	// Tokens are signed with the PEM private key file, RSA (RS256) or Ed25519 (EdDSA), and its public key is published
+	// on /.well-known/jwks.json with the verification keys, the previous signing keys still accepted during a rotation
+	// Each key only verifies the tokens signed with its own algorithm
+	// Without signing key file, tokens are signed with the HMAC secret (HS512)
+	JWTSecret                  string
+	JWTSigningKeyFile          string
+	JWTVerificationKeyFiles    []string
+	JWTExpirationTimeInMinutes int
+	JWTRefreshDeadlineInHours  int // lifetime of the refresh tokens
+	// Users found enabled by the auth middleware are not selected again during this time, 0 selects them on every request
+	// Disabled and deleted users are refused at once anyway, as their tokens are revoked
+	AuthUserCacheInSeconds int
+
+	// Two-factor authentication
+	MFAPendingTokenExpirationTimeInMinutes int
+	TOTPIssuer                             string // shown in the authenticator applications
+	TOTPSkewSteps                          int    // 1 means codes of the previous and next 30 seconds are accepted
+	RecoveryCodesCount                     int
+
+	// Login brute-force protection
+	// After the free attempts, each failure doubles the delay before the next attempt, from the base to the max delay
+	// After the max attempts, login is locked during the lockout duration
+	LoginFreeAttemptsPerAccount   int
+	LoginMaxAttemptsPerAccount    int
+	LoginFreeAttemptsPerIP        int
+	LoginMaxAttemptsPerIP         int
+	LoginBaseDelayInSeconds       int
+	LoginMaxDelayInSeconds        int
+	LoginLockoutDurationInMinutes int
+	LoginAttemptsMemoryInHours    int // failures are forgotten after this duration without new failure
+
+	// Password reset
+	PasswordResetExpirationTimeInMinutes int
+	PasswordResetURL                     string // page of the client application, the token is appended
+
+	// Email verification
+	EmailVerificationExpirationTimeInHours int
+	EmailVerificationResendDelayInSeconds  int
+	EmailVerificationURL                   string // the token is appended
+	VerifiedEmailRequiredToLogin           bool   // refuses the login of unverified users
+	VerifiedEmailRequiredForHouses         bool   // restricts the houses routes to verified users
+
+	// House invitations
+	InvitationExpirationTimeInHours int
+	InvitationURL                   string // page of the client application, the token is appended
+
+	// House ownership transfers
+	OwnershipTransferExpirationTimeInHours int
+	OwnershipTransferURL                   string // page of the client application, the house id is appended
+
+	// Mailer
+	MailerBackend   string // "log" writes the recipients and subjects of mails in the logs, "file" mails in .eml files, "smtp" sends them
+	MailerFrom      string
+	MailerDirectory string // used by the file backend
+	MailerQueueSize int    // mails are sent in background, and the queued ones sent before shutdown
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string // no authentication if empty
+	SMTPPassword    string
+
+	LimitElementsReturnedFromDatabase    int // default page size of the lists
+	MaxLimitElementsReturnedFromDatabase int // largest page size a client can ask for with the limit parameter
+}
+
+// SampleJWTSecret is the default HMAC secret, only accepted in dev status
+const SampleJWTSecret = "(;.W2g]z[eN@Eck["
 
-// Application status
 const CurrentAPIVersion = 0
-const DevStatus = true                       // dont forget to leave false for production
-const LimitElementsReturnedFromDatabase = 10 // used for some getAll methods
+
+// Current is the configuration used by the application, replaced by the loaded one at startup
+var Current = Default()
+
+// Default returns the configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		DevStatus:                false,
+		Port:                     5000,
+		ReadTimeoutInSeconds:     30,
+		ShutdownTimeoutInSeconds: 20,
+		MetricsEnabled:           false,
+		LogLevel:                 "info",
+
+		TracingExporter:     "none",
+		TracingOTLPEndpoint: "http://localhost:4318",
+		TracingServiceName:  "goapi",
+		TracingQueueSize:    2048,
+
+		DatabaseURI:                 "mongodb://localhost:27017",
+		DatabaseName:                "houses",
+		DatabaseSetupRetryInSeconds: 5,
+		HealthPingTimeoutInSeconds:  2,
+
+		DatabaseOperationTimeoutInMilliseconds: 5000,
+
+		JWTSecret:                  SampleJWTSecret,
+		JWTSigningKeyFile:          "",
+		JWTVerificationKeyFiles:    []string{},
+		JWTExpirationTimeInMinutes: 15,
+		JWTRefreshDeadlineInHours:  7 * 24,
+		AuthUserCacheInSeconds:     30,
+
+		MFAPendingTokenExpirationTimeInMinutes: 5,
+		TOTPIssuer:                             "GoAPI",
+		TOTPSkewSteps:                          1,
+		RecoveryCodesCount:                     10,
+
+		LoginFreeAttemptsPerAccount:   3,
+		LoginMaxAttemptsPerAccount:    10,
+		LoginFreeAttemptsPerIP:        10,
+		LoginMaxAttemptsPerIP:         50,
+		LoginBaseDelayInSeconds:       1,
+		LoginMaxDelayInSeconds:        60,
+		LoginLockoutDurationInMinutes: 15,
+		LoginAttemptsMemoryInHours:    24,
+
+		PasswordResetExpirationTimeInMinutes: 30,
+		PasswordResetURL:                     "http://localhost:3000/reset-password?token=",
+
+		EmailVerificationExpirationTimeInHours: 48,
+		EmailVerificationResendDelayInSeconds:  60,
+		EmailVerificationURL:                   "http://localhost:5000/v0/auth/verify-email?token=",
+		VerifiedEmailRequiredToLogin:           false,
+		VerifiedEmailRequiredForHouses:         false,
+
+		InvitationExpirationTimeInHours: 168,
+		InvitationURL:                   "http://localhost:3000/invitations?token=",
+
+		OwnershipTransferExpirationTimeInHours: 72,
+		OwnershipTransferURL:                   "http://localhost:3000/transfers?house=",
+
+		MailerBackend:   "log",
+		MailerFrom:      "GoAPI <no-reply@localhost>",
+		MailerDirectory: "mails",
+		MailerQueueSize: 100,
+		SMTPHost:        "localhost",
+		SMTPPort:        25,
+		SMTPUsername:    "",
+		SMTPPassword:    "",
+
+		LimitElementsReturnedFromDatabase:    10,
+		MaxLimitElementsReturnedFromDatabase: 100,
+	}
+}
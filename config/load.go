@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"goapi/logging"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const DefaultFile = "config/config.yml"
+const envPrefix = "GOAPI_"
+
+// Load returns the configuration merged from the defaults, the YAML config file, the environment variables and the
+// command line arguments, in this priority order
+// The config file path is set by the -config flag, a missing default file is ignored
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	flagSet := flag.NewFlagSet("goapi", flag.ContinueOnError)
+	file := flagSet.String("config", DefaultFile, "path of the YAML config file")
+	flagValues := map[string]*string{}
+	for _, field := range fields(cfg) {
+		flagValues[field.name] = flagSet.String(kebabCase(field.name), "", "overrides "+field.name)
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	fileIsSet := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			fileIsSet = true
+		}
+	})
+
+	if err := cfg.loadFile(*file, fileIsSet); err != nil {
+		return nil, err
+	}
+
+	// Environment variables then flags
+	for _, field := range fields(cfg) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(kebabCase(field.name), "-", "_"))
+		if value, ok := os.LookupEnv(name); ok {
+			if err := field.set(value); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %v", name, err)
+			}
+		}
+	}
+	var err error
+	flagSet.Visit(func(f *flag.Flag) {
+		for _, field := range fields(cfg) {
+			if err == nil && f.Name == kebabCase(field.name) {
+				if setErr := field.set(*flagValues[field.name]); setErr != nil {
+					err = fmt.Errorf("flag -%s: %v", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Reads the YAML file, its keys are the fields names
+func (cfg *Config) loadFile(path string, required bool) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for key, value := range values {
+		field, ok := fieldByName(cfg, key)
+		if !ok {
+			return fmt.Errorf("%s: unknown key %s", path, key)
+		}
+		// The value is decoded again in the field to use the yaml types conversion
+		encoded, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(encoded, field.value.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: key %s: %v", path, key, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the values, and refuses the sample JWT secret out of dev status
+func (cfg *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+
+	check(cfg.Port > 0 && cfg.Port < 65536, "Port must be between 1 and 65535")
+	check(cfg.ReadTimeoutInSeconds > 0, "ReadTimeoutInSeconds must be positive")
+	check(cfg.ShutdownTimeoutInSeconds > 0, "ShutdownTimeoutInSeconds must be positive")
+	_, err := logging.ParseLevel(cfg.LogLevel)
+	check(err == nil, "LogLevel must be debug, info, warn or error")
+	switch cfg.TracingExporter {
+	case "none":
+	case "otlp":
+		check(cfg.TracingOTLPEndpoint != "", "TracingOTLPEndpoint is required by the otlp exporter")
+		check(cfg.TracingServiceName != "", "TracingServiceName is required by the otlp exporter")
+		check(cfg.TracingQueueSize > 0, "TracingQueueSize must be positive")
+	default:
+		problems = append(problems, "TracingExporter must be none or otlp")
+	}
+	check(cfg.DatabaseURI != "", "DatabaseURI is required")
+	check(cfg.DatabaseName != "", "DatabaseName is required")
+	check(cfg.DatabaseSetupRetryInSeconds > 0, "DatabaseSetupRetryInSeconds must be positive")
+	check(cfg.HealthPingTimeoutInSeconds > 0, "HealthPingTimeoutInSeconds must be positive")
+	check(cfg.DatabaseOperationTimeoutInMilliseconds > 0, "DatabaseOperationTimeoutInMilliseconds must be positive")
+
+	if cfg.JWTSigningKeyFile == "" {
+		check(cfg.JWTSecret != "", "JWTSecret is required without JWTSigningKeyFile")
+		check(cfg.DevStatus || cfg.JWTSecret != SampleJWTSecret, "JWTSecret must be changed from the sample secret for production")
+		check(cfg.DevStatus || len(cfg.JWTSecret) >= 32, "JWTSecret must be at least 32 characters long for production")
+	}
+	check(cfg.JWTExpirationTimeInMinutes > 0, "JWTExpirationTimeInMinutes must be positive")
+	check(cfg.JWTRefreshDeadlineInHours > 0, "JWTRefreshDeadlineInHours must be positive")
+	check(cfg.AuthUserCacheInSeconds >= 0, "AuthUserCacheInSeconds must not be negative")
+
+	check(cfg.MFAPendingTokenExpirationTimeInMinutes > 0, "MFAPendingTokenExpirationTimeInMinutes must be positive")
+	check(cfg.TOTPIssuer != "", "TOTPIssuer is required")
+	check(cfg.TOTPSkewSteps >= 0, "TOTPSkewSteps must not be negative")
+	check(cfg.RecoveryCodesCount > 0, "RecoveryCodesCount must be positive")
+
+	check(cfg.LoginFreeAttemptsPerAccount >= 0, "LoginFreeAttemptsPerAccount must not be negative")
+	check(cfg.LoginMaxAttemptsPerAccount > cfg.LoginFreeAttemptsPerAccount, "LoginMaxAttemptsPerAccount must be greater than LoginFreeAttemptsPerAccount")
+	check(cfg.LoginFreeAttemptsPerIP >= 0, "LoginFreeAttemptsPerIP must not be negative")
+	check(cfg.LoginMaxAttemptsPerIP > cfg.LoginFreeAttemptsPerIP, "LoginMaxAttemptsPerIP must be greater than LoginFreeAttemptsPerIP")
+	check(cfg.LoginBaseDelayInSeconds > 0, "LoginBaseDelayInSeconds must be positive")
+	check(cfg.LoginMaxDelayInSeconds >= cfg.LoginBaseDelayInSeconds, "LoginMaxDelayInSeconds must not be lower than LoginBaseDelayInSeconds")
+	check(cfg.LoginLockoutDurationInMinutes > 0, "LoginLockoutDurationInMinutes must be positive")
+	check(cfg.LoginAttemptsMemoryInHours > 0, "LoginAttemptsMemoryInHours must be positive")
+
+	check(cfg.PasswordResetExpirationTimeInMinutes > 0, "PasswordResetExpirationTimeInMinutes must be positive")
+	check(cfg.PasswordResetURL != "", "PasswordResetURL is required")
+	check(cfg.EmailVerificationExpirationTimeInHours > 0, "EmailVerificationExpirationTimeInHours must be positive")
+	check(cfg.EmailVerificationResendDelayInSeconds >= 0, "EmailVerificationResendDelayInSeconds must not be negative")
+	check(cfg.EmailVerificationURL != "", "EmailVerificationURL is required")
+	check(cfg.InvitationExpirationTimeInHours > 0, "InvitationExpirationTimeInHours must be positive")
+	check(cfg.InvitationURL != "", "InvitationURL is required")
+	check(cfg.OwnershipTransferExpirationTimeInHours > 0, "OwnershipTransferExpirationTimeInHours must be positive")
+	check(cfg.OwnershipTransferURL != "", "OwnershipTransferURL is required")
+
+	switch cfg.MailerBackend {
+	case "log":
+	case "file":
+		check(cfg.MailerDirectory != "", "MailerDirectory is required by the file mailer")
+	case "smtp":
+		check(cfg.SMTPHost != "", "SMTPHost is required by the smtp mailer")
+		check(cfg.SMTPPort > 0 && cfg.SMTPPort < 65536, "SMTPPort must be between 1 and 65535")
+	default:
+		problems = append(problems, "MailerBackend must be log, file or smtp")
+	}
+	check(cfg.MailerFrom != "", "MailerFrom is required")
+	check(cfg.MailerQueueSize > 0, "MailerQueueSize must be positive")
+
+	check(cfg.LimitElementsReturnedFromDatabase > 0, "LimitElementsReturnedFromDatabase must be positive")
+	check(cfg.MaxLimitElementsReturnedFromDatabase >= cfg.LimitElementsReturnedFromDatabase, "MaxLimitElementsReturnedFromDatabase must be at least LimitElementsReturnedFromDatabase")
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// A settable field of the config
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+func fields(cfg *Config) []field {
+	value := reflect.ValueOf(cfg).Elem()
+	result := make([]field, value.NumField())
+	for i := range result {
+		result[i] = field{name: value.Type().Field(i).Name, value: value.Field(i)}
+	}
+	return result
+}
+
+func fieldByName(cfg *Config, name string) (field, bool) {
+	for _, f := range fields(cfg) {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Sets the field from its text form, used by the environment variables and the flags
+func (f field) set(text string) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(text)
+	case reflect.Int:
+		i, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", text)
+		}
+		f.value.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", text)
+		}
+		f.value.SetBool(b)
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// Converts a field name to kebab case, acronyms are kept as one word: JWTSigningKeyFile gives jwt-signing-key-file
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previousIsLower := !unicode.IsUpper(runes[i-1])
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousIsLower || nextIsLower {
+				builder.WriteRune('-')
+			}
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return builder.String()
+}
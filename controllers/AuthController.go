@@ -2,21 +2,24 @@ package controllers
 
 import (
 	"github.com/gofiber/fiber"
-	"goapi/config"
-	"goapi/errors/errorCodes"
+	"goapi/errors"
+	"goapi/middlewares"
 	"goapi/services"
+	"goapi/validation"
 )
 
 type AuthController struct {
 	AuthService services.AuthService
 	UserService services.UserService
+	MFAService  services.MFAService
 }
 
 // For Register see POST in UserController
 
 // Login method
 // Parse the email and password provided and pass them to the AuthService.Login method
-// If credentials match, a new JWT is sent, otherwise an error is sent
+// If credentials match, a new JWT and a refresh token are sent, otherwise an error is sent
+// Users with two-factor authentication get a MFA token instead, to send with their code to VerifyMFA
 // POST: http://localhost:8080/auth/login
 func (c *AuthController) Login(ctx *fiber.Ctx) {
 	var credentials struct {
@@ -24,19 +27,25 @@ func (c *AuthController) Login(ctx *fiber.Ctx) {
 		Password string `json:"password"`
 	}
 	err := ctx.BodyParser(&credentials)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
 
-	credentialMatch, newTokenSigned, statusCode, err, errorCode := c.AuthService.Login(credentials.Email, credentials.Password)
-	if !credentialMatch {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+	newTokenSigned, refreshToken, mfaToken, err := c.AuthService.Login(middlewares.RequestContext(ctx), credentials.Email, credentials.Password, ctx.IP())
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 
 	data := make(map[string]string)
-	data["token"] = newTokenSigned
+	if mfaToken != "" {
+		data["mfaRequired"] = "true"
+		data["mfaToken"] = mfaToken
+	} else {
+		data["token"] = newTokenSigned
+		data["refreshToken"] = refreshToken
+	}
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"data":    data,
@@ -44,56 +53,266 @@ func (c *AuthController) Login(ctx *fiber.Ctx) {
 }
 
 // JWT refresh method
-// Send the context to AuthService.JWTRefresh method that will parse the JWT and give a new one if possible
-// GET: http://localhost:8080/auth/refreshjwt
-func (c *AuthController) RefreshJWT(ctx *fiber.Ctx) {
-	newToken, statusCode, err, errorCode := c.AuthService.JWTRefresh(ctx)
+// Send the refresh token given at login to AuthService.Refresh method that will give a new JWT if possible
+// The refresh token can only be used once, the new one sent back must be used for the next refresh
+// POST: http://localhost:8080/auth/refresh
+func (c *AuthController) Refresh(ctx *fiber.Ctx) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
 
+	newToken, newRefreshToken, err := c.AuthService.Refresh(middlewares.RequestContext(ctx), body.RefreshToken)
 	if err != nil {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
 	data["token"] = newToken
+	data["refreshToken"] = newRefreshToken
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"data":    data,
 	})
 }
 
-// POST: http://localhost:8080/auth/resetpassword
+// Logout method
+// Revokes the JWT used for this request, and the refresh token of this session if it is given
+// POST: http://localhost:8080/auth/logout
+func (c *AuthController) Logout(ctx *fiber.Ctx) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	_ = ctx.BodyParser(&body) // the refresh token is optional
+
+	principal, _ := middlewares.PrincipalFromCtx(ctx)
+	err := c.AuthService.Logout(middlewares.RequestContext(ctx), principal, body.RefreshToken)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Logout from all sessions method
+// Revokes every JWT and refresh token of the user
+// POST: http://localhost:8080/auth/logout-all
+func (c *AuthController) LogoutAll(ctx *fiber.Ctx) {
+	err := c.AuthService.LogoutAll(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx).UserID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Forgotten password method
+// Parse the email provided and pass it to the AuthService.ForgotPassword method that will mail a reset link
+// The response is the same whether the email address exists or not
+// POST: http://localhost:8080/auth/password/forgot
+func (c *AuthController) ForgotPassword(ctx *fiber.Ctx) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+
+	err = c.AuthService.ForgotPassword(middlewares.RequestContext(ctx), body.Email)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Reset password method
+// Parse the reset token received by email and the new password and pass them to the AuthService.ResetPassword method
+// Every session of the user is revoked, he must login again with the new password
+// POST: http://localhost:8080/auth/password/reset
 func (c *AuthController) ResetPassword(ctx *fiber.Ctx) {
+	var body struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8,max=128"` // same rules as models.User
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(body)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.AuthService.ResetPassword(middlewares.RequestContext(ctx), body.Token, body.Password)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Email verification method
+// Pass the token of the verification link to the AuthService.VerifyEmail method
+// GET: http://localhost:8080/auth/verify-email?token=
+func (c *AuthController) VerifyEmail(ctx *fiber.Ctx) {
+	err := c.AuthService.VerifyEmail(middlewares.RequestContext(ctx), ctx.Query("token"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
 
-	// check get by email
-	// if no email return false
+// Resend email verification method
+// Sends a new verification link to the user of the JWT, this can not be asked too often
+// POST: http://localhost:8080/auth/verify-email/resend
+func (c *AuthController) ResendEmailVerification(ctx *fiber.Ctx) {
+	err := c.AuthService.ResendEmailVerification(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx).UserID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
 
-	// check if pass are same
-	// if not return false
+// MFA verification method
+// Second step of the login for the users with two-factor authentication
+// Parse the MFA token given by login and the code of the authenticator application, or a recovery code,
+// and pass them to the MFAService.Verify method, a new JWT and a refresh token are sent if they match
+// POST: http://localhost:8080/auth/mfa/verify
+func (c *AuthController) VerifyMFA(ctx *fiber.Ctx) {
+	var body struct {
+		MFAToken string `json:"mfaToken"`
+		Code     string `json:"code"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+
+	newToken, refreshToken, err := c.MFAService.Verify(middlewares.RequestContext(ctx), body.MFAToken, body.Code)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["token"] = newToken
+	data["refreshToken"] = refreshToken
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
 
-	userID := "TODO"
-	// generate new token
-	newToken := c.AuthService.JwtGenerate(userID)
-	newTokenString, err := newToken.SignedString([]byte(config.HmacSampleSecret))
+// MFA enrollment method
+// Starts the two-factor authentication enrollment of the user of the JWT
+// Sends the secret and the otpauth URI to add in an authenticator application
+// POST: http://localhost:8080/auth/mfa/enroll
+func (c *AuthController) EnrollMFA(ctx *fiber.Ctx) {
+	secret, uri, err := c.MFAService.Enroll(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx))
 	if err != nil {
-		_ = ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.InternalServerError,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
-	data["token"] = newTokenString
+	data["secret"] = secret
+	data["uri"] = uri
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"data":    data,
 	})
 }
 
+// MFA confirmation method
+// Enables two-factor authentication with a first code of the authenticator application
+// Sends the recovery codes, they will never be sent again
+// POST: http://localhost:8080/auth/mfa/confirm
+func (c *AuthController) ConfirmMFA(ctx *fiber.Ctx) {
+	code, ok := parseMFACode(ctx)
+	if !ok {
+		return
+	}
+	recoveryCodes, err := c.MFAService.Confirm(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), code)
+	sendRecoveryCodes(ctx, recoveryCodes, err)
+}
+
+// MFA recovery codes method
+// Replaces the recovery codes, a code of the authenticator application is required
+// POST: http://localhost:8080/auth/mfa/recovery-codes
+func (c *AuthController) RegenerateRecoveryCodes(ctx *fiber.Ctx) {
+	code, ok := parseMFACode(ctx)
+	if !ok {
+		return
+	}
+	recoveryCodes, err := c.MFAService.RegenerateRecoveryCodes(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), code)
+	sendRecoveryCodes(ctx, recoveryCodes, err)
+}
+
+// MFA disabling method
+// Disables two-factor authentication, a code of the authenticator application or a recovery code is required
+// POST: http://localhost:8080/auth/mfa/disable
+func (c *AuthController) DisableMFA(ctx *fiber.Ctx) {
+	code, ok := parseMFACode(ctx)
+	if !ok {
+		return
+	}
+	err := c.MFAService.Disable(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), code)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Parse the code of the MFA requests, sends an error and returns false if the body is not valid
+func parseMFACode(ctx *fiber.Ctx) (string, bool) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return "", false
+	}
+	return body.Code, true
+}
+
+// Sends the recovery codes returned by the MFA service, or its error
+func sendRecoveryCodes(ctx *fiber.Ctx, recoveryCodes []string, err error) {
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    fiber.Map{"recoveryCodes": recoveryCodes},
+	})
+}
+
 /*
 // GetAll userID from token
 user := ctx.Locals("user").(*jwt.Token)
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/services"
+)
+
+type HealthController struct {
+	Service services.HealthService
+}
+
+// Liveness probe, tells that the process is up
+// The response is not wrapped in the usual success/data object, as orchestrators only read the status code
+// GET http://localhost:5000/healthz
+func (c *HealthController) Liveness(ctx *fiber.Ctx) {
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	_ = ctx.Status(fiber.StatusOK).JSON(c.Service.Liveness())
+}
+
+// Readiness probe, tells if the server can handle requests, with the detail of each dependency
+// Responds 503 while MongoDB does not answer or the startup steps (indexes, migrations) are not done
+// GET http://localhost:5000/readyz
+func (c *HealthController) Readiness(ctx *fiber.Ctx) {
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ready, health := c.Service.Readiness()
+	statusCode := fiber.StatusOK
+	if !ready {
+		statusCode = fiber.StatusServiceUnavailable
+	}
+	_ = ctx.Status(statusCode).JSON(health)
+}
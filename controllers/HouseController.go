@@ -1,13 +1,12 @@
 package controllers
 
 import (
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gofiber/fiber"
-	"goapi/config"
-	"goapi/errors/errorCodes"
-	"goapi/errors/errorDesc"
+	"goapi/errors"
+	"goapi/middlewares"
 	"goapi/models"
 	"goapi/services"
+	"goapi/validation"
 )
 
 type HouseController struct {
@@ -16,49 +15,29 @@ type HouseController struct {
 
 // Post a house
 // Note you must provide a name to insert a house, the userID will be extracted from JWT
-// "rooms" is an array of models.room objects, you can insert as many as you want
+// "rooms" is an array of models.room objects, you can insert up to 100 of them
 // Note you must provide a name and surface > 0 for each room
 // POST http://localhost:5000/houses
 func (c *HouseController) Post(ctx *fiber.Ctx) {
 	var house models.House
 	err := ctx.BodyParser(&house)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.BadRequest,
-		})
+		middlewares.Fail(ctx, errors.InvalidBody(err))
 		return
 	}
-	// Check if each room fields is filled
-	oneRoomFieldEmpty := false
-	for _, room := range *house.Rooms {
-		if room.Name == "" || room.Surface == 0 {
-			oneRoomFieldEmpty = true
-		}
-	}
-	// GetAll the user ID from its JWT
-	user := ctx.Locals("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	house.UserID = claims["sub"].(string)
-	// Check if the required fields are filled
-	if house.Name == "" || oneRoomFieldEmpty {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     errorDesc.RequiredFieldEmpty,
-			"errorCode": errorCodes.BadRequest,
-		})
+	// Check the house and its rooms, see the validate tags of their models
+	err = validation.Validate(house)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
+	// GetAll the user ID from its JWT
+	house.UserID = middlewares.CallerFromCtx(ctx).UserID
 
 	// UserID will be checked in service in order to be sure user exists
-	statusCode, insertedHouseID, err, errorCode := c.Service.Insert(house)
+	insertedHouseID, err := c.Service.Insert(middlewares.RequestContext(ctx), house)
 	if err != nil {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
@@ -69,36 +48,30 @@ func (c *HouseController) Post(ctx *fiber.Ctx) {
 	})
 }
 
-// DEV STATUS ONLY
-// Returns a list of the houses
-// GET http://localhost:5000/houses
+// ADMIN ONLY
+// Returns a page of the houses, see houseListParams for the sort and filter parameters
+// GET http://localhost:5000/admin/houses?limit=10&sort=-totalSurface,name&city=Paris&minSurface=50
 func (c *HouseController) GetAll(ctx *fiber.Ctx) {
-	houses, err := c.Service.GetAll(config.LimitElementsReturnedFromDatabase) // limits the nb of returned houses
+	query, err := parseListQuery(ctx, houseListParams)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
-	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    houses,
-	})
+	houses, page, err := c.Service.GetAll(middlewares.RequestContext(ctx), query)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	sendPage(ctx, houses, page)
 }
 
 // Returns house by its id
 // GET http://localhost:5000/houses/id
 func (c *HouseController) GetByID(ctx *fiber.Ctx) {
 	id := ctx.Params("id")
-	house, err := c.Service.GetByID(id)
+	house, err := c.Service.GetByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -107,27 +80,25 @@ func (c *HouseController) GetByID(ctx *fiber.Ctx) {
 	})
 }
 
-// Returns house by its id
-// GET http://localhost:5000/houses/ofUser/id
+// Returns a page of the houses of an user, the ones he owns and the ones shared with him, with the same parameters as GetAll
+// GET http://localhost:5000/houses/ofUser/id?sort=name
 func (c *HouseController) GetByUserID(ctx *fiber.Ctx) {
 	id := ctx.Params("id")
-	houses, err := c.Service.GetByUserID(id)
+	query, err := parseListQuery(ctx, houseListParams)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
-	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    houses,
-	})
+	houses, page, err := c.Service.GetByUserID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id, query)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	sendPage(ctx, houses, page)
 }
 
 // Updates a house (JSON accepted only)
-// This method can be used to updates the userID, name or rooms fields
+// This method can be used to updates the name, city or rooms fields, the owner is changed with an ownership transfer
 // You can update the ones that you want, but a room must contain all its fields
 // PATCH http://localhost:5000/houses/id
 func (c *HouseController) PatchBy(ctx *fiber.Ctx) {
@@ -136,36 +107,21 @@ func (c *HouseController) PatchBy(ctx *fiber.Ctx) {
 	var house models.House
 	err := ctx.BodyParser(&house)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.BadRequest,
-		})
+		middlewares.Fail(ctx, errors.InvalidBody(err))
 		return
 	}
-	if house.Rooms != nil {
-		// Check if each room fields is well filled
-		for _, room := range *house.Rooms {
-			if room.Name == "" || room.Surface == 0 {
-				_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"success":   false,
-					"error":     errorDesc.RequiredFieldEmpty,
-					"errorCode": errorCodes.BadRequest,
-				})
-				return
-			}
-		}
+	// Check the updated fields, each room must be complete
+	err = validation.ValidateUpdate(house)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
 	}
 
 
 	// Send the update request to service and parse results
-	statusCode, hasBeenUpdated, err, errorCode := c.Service.UpdateByID(id, house)
-	if !hasBeenUpdated {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+	err = c.Service.UpdateByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id, house)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
@@ -180,21 +136,9 @@ func (c *HouseController) PatchBy(ctx *fiber.Ctx) {
 // DELETE http://localhost:5000/houses/id
 func (c *HouseController) DeleteBy(ctx *fiber.Ctx) {
 	id := ctx.Params("id")
-	hasBeenDeleted, err := c.Service.DeleteByID(id)
+	err := c.Service.DeleteByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
-		return
-	}
-	if !hasBeenDeleted { // Should never occur but just in case
-		_ = ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success":   false,
-			"error":     errorDesc.Unknown,
-			"errorCode": errorCodes.InternalServerError,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
@@ -204,3 +148,19 @@ func (c *HouseController) DeleteBy(ctx *fiber.Ctx) {
 		"data":    data,
 	})
 }
+
+// Returns a page of the history of a house, e.g. its ownership transfers, with the limit, sort and cursor parameters
+// GET http://localhost:5000/houses/id/history?sort=-at
+func (c *HouseController) GetHistory(ctx *fiber.Ctx) {
+	query, err := parseListQuery(ctx, houseEventListParams)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	events, page, err := c.Service.GetHistory(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), query)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	sendPage(ctx, events, page)
+}
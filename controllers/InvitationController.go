@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/middlewares"
+	"goapi/models"
+	"goapi/services"
+	"goapi/validation"
+)
+
+// Invitations are sent by email by the owner of a house :id, to make someone a member of it
+type InvitationController struct {
+	Service services.InvitationService
+}
+
+// The body of the accept and decline routes, the token comes from the link of the invitation email
+type invitationTokenBody struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Invites someone to a house, a link is sent to his email address
+// Note you must provide the email address and the role "editor" or "viewer"
+// POST http://localhost:5000/houses/id/invitations
+func (c *InvitationController) Post(ctx *fiber.Ctx) {
+	var invitation models.Invitation
+	err := ctx.BodyParser(&invitation)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(invitation)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	insertedInvitationID, err := c.Service.Insert(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), invitation)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["insertedInvitationID"] = insertedInvitationID
+	_ = ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Returns the pending invitations of a house
+// GET http://localhost:5000/houses/id/invitations
+func (c *InvitationController) GetAll(ctx *fiber.Ctx) {
+	invitations, err := c.Service.GetPendingOfHouse(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    invitations,
+	})
+}
+
+// Revokes a pending invitation of a house
+// DELETE http://localhost:5000/houses/id/invitations/invitationId
+func (c *InvitationController) DeleteBy(ctx *fiber.Ctx) {
+	invitationID := ctx.Params("invitationId")
+	err := c.Service.Revoke(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), invitationID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["revokedID"] = invitationID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Accepts an invitation, the user must be logged in with the email address it was sent to
+// POST http://localhost:5000/invitations/accept
+func (c *InvitationController) Accept(ctx *fiber.Ctx) {
+	var body invitationTokenBody
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(body)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	houseID, err := c.Service.Accept(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), body.Token)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["houseID"] = houseID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Declines an invitation, no account is needed
+// POST http://localhost:5000/invitations/decline
+func (c *InvitationController) Decline(ctx *fiber.Ctx) {
+	var body invitationTokenBody
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(body)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.Service.Decline(middlewares.RequestContext(ctx), body.Token)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
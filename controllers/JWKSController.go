@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/signing"
+)
+
+type JWKSController struct {
+	KeySet *signing.KeySet
+}
+
+// Returns the public keys verifying the JWTs, in the JWKS format
+// Other services can verify the tokens with them, without sharing any secret
+// The response is not wrapped in the usual success/data object, as expected by JWT libraries
+// GET http://localhost:5000/.well-known/jwks.json
+func (c *JWKSController) Get(ctx *fiber.Ctx) {
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	_ = ctx.Status(fiber.StatusOK).JSON(c.KeySet.JWKS())
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber"
+	"goapi/config"
+	"goapi/errors"
+	"goapi/errors/errorCodes"
+	"goapi/errors/errorDesc"
+	"goapi/models"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The query parameters of a list route, on top of limit, sort and cursor:
+// the fields which can sort it, e.g. sort=-totalSurface,name, and its filters
+type listParams struct {
+	sortFields []string
+	filters    []filterParam
+}
+
+// A filter of a list route, e.g. the parameter "name~" keeps the elements whose name contains its value
+type filterParam struct {
+	name     string
+	field    string
+	operator string
+	kind     string // "string", "int" or "bool", the type of the value
+}
+
+// The lists of houses, the houses of an user are filtered by their owner and members too
+var houseListParams = listParams{
+	sortFields: []string{"name", "city", "totalSurface"},
+	filters: []filterParam{
+		{name: "userID", field: "userID", operator: models.FilterEqual, kind: "string"},
+		{name: "name", field: "name", operator: models.FilterEqual, kind: "string"},
+		{name: "name~", field: "name", operator: models.FilterContains, kind: "string"},
+		{name: "city", field: "city", operator: models.FilterEqual, kind: "string"},
+		{name: "city~", field: "city", operator: models.FilterContains, kind: "string"},
+		{name: "minSurface", field: "totalSurface", operator: models.FilterMin, kind: "int"},
+		{name: "maxSurface", field: "totalSurface", operator: models.FilterMax, kind: "int"},
+	},
+}
+
+// The history of a house, in the order of the events by default
+var houseEventListParams = listParams{
+	sortFields: []string{"at"},
+	filters: []filterParam{
+		{name: "type", field: "type", operator: models.FilterEqual, kind: "string"},
+	},
+}
+
+var userListParams = listParams{
+	sortFields: []string{"firstName", "lastName", "email"},
+	filters: []filterParam{
+		{name: "firstName~", field: "firstName", operator: models.FilterContains, kind: "string"},
+		{name: "lastName~", field: "lastName", operator: models.FilterContains, kind: "string"},
+		{name: "email", field: "email", operator: models.FilterEqual, kind: "string"},
+		{name: "email~", field: "email", operator: models.FilterContains, kind: "string"},
+		{name: "language", field: "language", operator: models.FilterEqual, kind: "string"},
+		{name: "role", field: "roles", operator: models.FilterEqual, kind: "string"},
+		{name: "verified", field: "verified", operator: models.FilterEqual, kind: "bool"},
+		{name: "enabled", field: "enabled", operator: models.FilterEqual, kind: "bool"},
+	},
+}
+
+// Reads the page asked by the query parameters of a list route
+// Without parameters, it is the first page of LimitElementsReturnedFromDatabase elements, sorted by id
+// Empty filters are ignored, every invalid parameter is sent at once in the details of a validation error
+func parseListQuery(ctx *fiber.Ctx, params listParams) (models.ListQuery, error) {
+	query := models.ListQuery{Limit: config.Current.LimitElementsReturnedFromDatabase, Cursor: ctx.Query("cursor")}
+	var details []errors.FieldError
+
+	if limit := ctx.Query("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		maxLimit := config.Current.MaxLimitElementsReturnedFromDatabase
+		switch {
+		case err != nil:
+			details = append(details, errors.FieldError{Field: "limit", Code: errorCodes.FieldInvalid, Description: errorDesc.FieldNotAnInteger})
+		case query.Limit < 1:
+			details = append(details, errors.FieldError{Field: "limit", Code: errorCodes.FieldTooSmall, Description: fmt.Sprintf(errorDesc.FieldTooSmall, 1)})
+		case query.Limit > maxLimit:
+			details = append(details, errors.FieldError{Field: "limit", Code: errorCodes.FieldTooLarge, Description: fmt.Sprintf(errorDesc.FieldTooLarge, maxLimit)})
+		}
+	}
+
+	sorted := map[string]bool{}
+	for _, field := range strings.Split(ctx.Query("sort"), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		s := models.SortField{Field: strings.TrimPrefix(field, "-"), Descending: strings.HasPrefix(field, "-")}
+		if !contains(params.sortFields, s.Field) {
+			details = append(details, errors.FieldError{Field: "sort", Code: errorCodes.FieldNotAllowed, Description: fmt.Sprintf(errorDesc.FieldNotAllowed, strings.Join(params.sortFields, ", "))})
+			continue
+		}
+		if !sorted[s.Field] { // only the first one sorts
+			sorted[s.Field] = true
+			query.Sort = append(query.Sort, s)
+		}
+	}
+
+	for _, filter := range params.filters {
+		value := ctx.Query(filter.name)
+		if value == "" {
+			continue
+		}
+		f := models.Filter{Field: filter.field, Operator: filter.operator, Value: value}
+		switch filter.kind {
+		case "int":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				details = append(details, errors.FieldError{Field: filter.name, Code: errorCodes.FieldInvalid, Description: errorDesc.FieldNotAnInteger})
+				continue
+			}
+			f.Value = number
+		case "bool":
+			if value != "true" && value != "false" {
+				details = append(details, errors.FieldError{Field: filter.name, Code: errorCodes.FieldInvalid, Description: errorDesc.FieldNotABoolean})
+				continue
+			}
+			f.Value = value == "true"
+		}
+		query.Filters = append(query.Filters, f)
+	}
+
+	if len(details) > 0 {
+		return models.ListQuery{}, errors.ValidationFailed.WithDetails(details...)
+	}
+	return query, nil
+}
+
+// Sends a page of a list, with the number of elements matching the filters
+// and the links of the next and previous pages, null when there is no such page
+func sendPage(ctx *fiber.Ctx, data interface{}, page models.Page) {
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+		"meta": fiber.Map{
+			"total": page.Total,
+			"next":  pageLink(ctx, page.Next),
+			"prev":  pageLink(ctx, page.Prev),
+		},
+	})
+}
+
+// The link of the page of a cursor: the same route with the same parameters but the cursor
+func pageLink(ctx *fiber.Ctx, cursor string) interface{} {
+	if cursor == "" {
+		return nil
+	}
+	query, _ := url.ParseQuery(string(ctx.Fasthttp.URI().QueryString()))
+	query.Set("cursor", cursor)
+	return ctx.Path() + "?" + query.Encode()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/middlewares"
+	"goapi/models"
+	"goapi/services"
+	"goapi/validation"
+)
+
+// Members share a house with its owner, each route works on the members of the house :id
+// A member is an editor, who can update the house and its rooms, or a viewer, who can only read them
+type MemberController struct {
+	Service services.HouseService
+}
+
+// Returns the users of a house, its owner first
+// GET http://localhost:5000/houses/id/members
+func (c *MemberController) GetAll(ctx *fiber.Ctx) {
+	members, err := c.Service.GetMembers(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    members,
+	})
+}
+
+// Adds a member to a house
+// Note you must provide the userID of an existing user, and the role "editor" or "viewer"
+// POST http://localhost:5000/houses/id/members
+func (c *MemberController) Post(ctx *fiber.Ctx) {
+	var member models.HouseMember
+	err := ctx.BodyParser(&member)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(member)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.Service.InsertMember(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), member)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["insertedMemberID"] = member.UserID
+	_ = ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Changes the role of a member of a house (JSON accepted only)
+// PATCH http://localhost:5000/houses/id/members/userId
+func (c *MemberController) PatchBy(ctx *fiber.Ctx) {
+	userID := ctx.Params("userId")
+	var body struct {
+		Role string `json:"role" validate:"required,oneof=editor viewer"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(body)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.Service.UpdateMember(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), userID, body.Role)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["updatedID"] = userID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Removes a member from a house, a member can remove himself to leave the house
+// DELETE http://localhost:5000/houses/id/members/userId
+func (c *MemberController) DeleteBy(ctx *fiber.Ctx) {
+	userID := ctx.Params("userId")
+	err := c.Service.DeleteMember(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), userID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["deletedID"] = userID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/metrics"
+)
+
+type MetricsController struct {
+	Registry *metrics.Registry
+}
+
+// Returns the metrics in the Prometheus text exposition format
+// GET http://localhost:5000/metrics
+func (c *MetricsController) Get(ctx *fiber.Ctx) {
+	ctx.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	ctx.Status(fiber.StatusOK)
+	if err := c.Registry.WriteText(ctx.Fasthttp.Response.BodyWriter()); err != nil {
+		ctx.Status(fiber.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/middlewares"
+	"goapi/models"
+	"goapi/services"
+	"goapi/validation"
+)
+
+// Rooms are a sub-resource of the houses, each route works on the rooms of the house :id
+type RoomController struct {
+	Service services.HouseService
+}
+
+// Post a room in a house
+// Note you must provide a name and a surface > 0, the id of the room is generated
+// POST http://localhost:5000/houses/id/rooms
+func (c *RoomController) Post(ctx *fiber.Ctx) {
+	var room models.Room
+	err := ctx.BodyParser(&room)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(room)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	insertedRoomID, err := c.Service.InsertRoom(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), room)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["insertedRoomID"] = insertedRoomID
+	_ = ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Returns the rooms of a house
+// GET http://localhost:5000/houses/id/rooms
+func (c *RoomController) GetAll(ctx *fiber.Ctx) {
+	rooms, err := c.Service.GetRooms(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    rooms,
+	})
+}
+
+// Returns a room of a house by its id
+// GET http://localhost:5000/houses/id/rooms/roomId
+func (c *RoomController) GetByID(ctx *fiber.Ctx) {
+	room, err := c.Service.GetRoom(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), ctx.Params("roomId"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    room,
+	})
+}
+
+// Updates a room of a house (JSON accepted only)
+// This method can be used to update the name, description or surface fields, the other rooms are not changed
+// PATCH http://localhost:5000/houses/id/rooms/roomId
+func (c *RoomController) PatchBy(ctx *fiber.Ctx) {
+	roomID := ctx.Params("roomId")
+	var room models.Room
+	err := ctx.BodyParser(&room)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.ValidateUpdate(room)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.Service.UpdateRoom(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), roomID, room)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["updatedID"] = roomID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// Deletes a room of a house
+// DELETE http://localhost:5000/houses/id/rooms/roomId
+func (c *RoomController) DeleteBy(ctx *fiber.Ctx) {
+	roomID := ctx.Params("roomId")
+	err := c.Service.DeleteRoom(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), roomID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["deletedID"] = roomID
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
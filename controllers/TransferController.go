@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/middlewares"
+	"goapi/models"
+	"goapi/services"
+	"goapi/validation"
+)
+
+// The ownership of the house :id is transferred in two steps: its owner asks for it, then the recipient accepts it
+type TransferController struct {
+	Service services.HouseService
+}
+
+// Asks another user to become the owner of a house
+// Note you must provide the toUserID of the recipient, he is told by email
+// POST http://localhost:5000/houses/id/transfer
+func (c *TransferController) Post(ctx *fiber.Ctx) {
+	var transfer models.OwnershipTransfer
+	err := ctx.BodyParser(&transfer)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.Validate(transfer)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+
+	err = c.Service.RequestTransfer(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"), transfer.ToUserID)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Accepts the pending ownership transfer of a house, the user becomes its owner
+// POST http://localhost:5000/houses/id/transfer/accept
+func (c *TransferController) Accept(ctx *fiber.Ctx) {
+	err := c.Service.AcceptTransfer(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// Cancels the pending ownership transfer of a house, or declines it for its recipient
+// DELETE http://localhost:5000/houses/id/transfer
+func (c *TransferController) DeleteBy(ctx *fiber.Ctx) {
+	err := c.Service.CancelTransfer(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), ctx.Params("id"))
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+	})
+}
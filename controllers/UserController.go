@@ -2,11 +2,11 @@ package controllers
 
 import (
 	"github.com/gofiber/fiber"
-	"goapi/config"
-	"goapi/errors/errorCodes"
-	"goapi/errors/errorDesc"
+	"goapi/errors"
+	"goapi/middlewares"
 	"goapi/models"
 	"goapi/services"
+	"goapi/validation"
 )
 
 type UserController struct {
@@ -18,53 +18,38 @@ type UserController struct {
 // This method can be used a register
 // Note that you must provide a firstName, lastName, email, password and language
 // These are the required fields to insert an user in the database
-// If everything is good it will return a JWT to interact with the other routes
+// If everything is good it will return a JWT to interact with the other routes and a refresh token
 // POST http://localhost:5000/users
 func (c *UserController) Post(ctx *fiber.Ctx) {
 	user := models.User{}
 	err := ctx.BodyParser(&user)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.BadRequest,
-		})
+		middlewares.Fail(ctx, errors.InvalidBody(err))
 		return
 	}
 
-	// Returns an error if one of the required fields is empty
-	if user.FirstName == "" || user.LastName == "" || user.Email == "" || user.Password == "" || user.Language == "" {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     errorDesc.RequiredFieldEmpty,
-			"errorCode": errorCodes.BadRequest,
-		})
+	// Returns an error with all the invalid fields, see the validate tags of the user model
+	err = validation.Validate(user)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 
-	statusCode, insertedUserID, err, errorCode := c.UserService.Insert(user)
+	insertedUserID, err := c.UserService.Insert(middlewares.RequestContext(ctx), user)
 	if err != nil {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 
-	// Generate the JWT and sign it
-	token := c.AuthService.JwtGenerate(insertedUserID)
-	tokenString, err := token.SignedString([]byte(config.HmacSampleSecret))
+	// Generate the JWT and the refresh token
+	tokenString, refreshToken, err := c.AuthService.GenerateTokens(middlewares.RequestContext(ctx), insertedUserID, models.DefaultRoles)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.InternalServerError,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
 	data["token"] = tokenString
+	data["refreshToken"] = refreshToken
 	data["userID"] = insertedUserID
 	_ = ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
@@ -72,36 +57,30 @@ func (c *UserController) Post(ctx *fiber.Ctx) {
 	})
 }
 
-// DEV STATUS ONLY
-// Returns list of the users
-// GET http://localhost:5000/users
+// ADMIN ONLY
+// Returns a page of the users, see userListParams for the sort and filter parameters
+// GET http://localhost:5000/admin/users?limit=10&sort=lastName,firstName&role=admin
 func (c *UserController) GetAll(ctx *fiber.Ctx) {
-	users, err := c.UserService.GetAll(config.LimitElementsReturnedFromDatabase)
+	query, err := parseListQuery(ctx, userListParams)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.InternalServerError,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
-	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
-		"success": true,
-		"data":    users,
-	})
+	users, page, err := c.UserService.GetAll(middlewares.RequestContext(ctx), query)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	sendPage(ctx, users, page)
 }
 
 // Returns an user by its id
 // GET http://localhost:5000/users/id
 func (c *UserController) GetByID(ctx *fiber.Ctx) {
 	id := ctx.Params("id")
-	user, err := c.UserService.GetByID(id)
+	user, err := c.UserService.GetByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
@@ -121,22 +100,19 @@ func (c *UserController) PatchBy(ctx *fiber.Ctx) {
 	err := ctx.BodyParser(&user)
 	user.ID = ""
 	if err != nil {
-		_ = ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.BadRequest,
-		})
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+	err = validation.ValidateUpdate(user)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 
 	// Send the update request to service and parse results
-	statusCode, hasBeenUpdated, err, errorCode := c.UserService.UpdateByID(id, user)
-	if !hasBeenUpdated {
-		_ = ctx.Status(statusCode).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCode,
-		})
+	err = c.UserService.UpdateByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id, user)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
@@ -151,25 +127,90 @@ func (c *UserController) PatchBy(ctx *fiber.Ctx) {
 // DELETE http://localhost:5000/users/id
 func (c *UserController) DeleteBy(ctx *fiber.Ctx) {
 	id := ctx.Params("id")
-	hasBeenDeleted, err := c.UserService.DeleteByID(id)
+	err := c.UserService.DeleteByID(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id)
 	if err != nil {
-		_ = ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success":   false,
-			"error":     err.Error(),
-			"errorCode": errorCodes.ResourceNotFound,
-		})
+		middlewares.Fail(ctx, err)
 		return
 	}
-	if !hasBeenDeleted { // Should never occur but just in case
-		_ = ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success":   false,
-			"error":     errorDesc.Unknown,
-			"errorCode": errorCodes.InternalServerError,
-		})
+	data := make(map[string]string)
+	data["deletedID"] = id
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// ADMIN ONLY
+// Disables an user, it will not be able to login or refresh its JWT anymore
+// PATCH http://localhost:5000/admin/users/id/disable
+func (c *UserController) Disable(ctx *fiber.Ctx) {
+	c.setEnabled(ctx, false)
+}
+
+// ADMIN ONLY
+// Enables an user again
+// PATCH http://localhost:5000/admin/users/id/enable
+func (c *UserController) Enable(ctx *fiber.Ctx) {
+	c.setEnabled(ctx, true)
+}
+
+// Shared by Disable and Enable, sends the status update to service and parse results
+func (c *UserController) setEnabled(ctx *fiber.Ctx, enabled bool) {
+	id := ctx.Params("id")
+	err := c.UserService.SetEnabled(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id, enabled)
+	if err != nil {
+		middlewares.Fail(ctx, err)
 		return
 	}
 	data := make(map[string]string)
-	data["deletedID"] = id
+	data["updatedID"] = id
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// ADMIN ONLY
+// Unlocks the login of an user, locked after too many failed attempts
+// PATCH http://localhost:5000/admin/users/id/unlock
+func (c *UserController) Unlock(ctx *fiber.Ctx) {
+	id := ctx.Params("id")
+	err := c.AuthService.UnlockAccount(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["updatedID"] = id
+	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// ADMIN ONLY
+// Replaces the roles of an user (JSON accepted only)
+// Expects a "roles" array, built-in roles are "user" and "admin" but custom roles are accepted
+// The new roles will be in the user JWT after its next login or refresh
+// PUT http://localhost:5000/admin/users/id/roles
+func (c *UserController) PutRoles(ctx *fiber.Ctx) {
+	id := ctx.Params("id")
+	var body struct {
+		Roles []string `json:"roles"`
+	}
+	err := ctx.BodyParser(&body)
+	if err != nil {
+		middlewares.Fail(ctx, errors.InvalidBody(err))
+		return
+	}
+
+	err = c.UserService.SetRoles(middlewares.RequestContext(ctx), middlewares.CallerFromCtx(ctx), id, body.Roles)
+	if err != nil {
+		middlewares.Fail(ctx, err)
+		return
+	}
+	data := make(map[string]string)
+	data["updatedID"] = id
 	_ = ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"success": true,
 		"data":    data,
@@ -0,0 +1,84 @@
+package errors
+
+import (
+	"errors"
+	"goapi/errors/errorCodes"
+	"time"
+)
+
+// APIError is an error sent to the client, with its HTTP status, its code and its description
+// Services return them, and the error middleware sends them in the error envelope:
+// {"success": false, "error": description, "errorCode": code, "details": [...], "requestID": "..."}
+type APIError struct {
+	Status      int
+	Code        string        // see errorCodes, the client application reacts depending on it
+	Description string        // see errorDesc, details for the developers
+	Details     []FieldError  // the invalid fields of the request body, if any
+	RetryAfter  time.Duration // the delay before the request can be sent again, sent in the Retry-After header, if any
+	Cause       error         // the internal error, written in the logs but never sent to the client
+}
+
+// FieldError tells what is wrong with a field of the request body
+// The field is its JSON path, e.g. rooms[0].name
+type FieldError struct {
+	Field       string `json:"field"`
+	Code        string `json:"errorCode"`
+	Description string `json:"error"`
+}
+
+// New returns an API error, see the errors of this package before creating one
+func New(status int, code string, description string) *APIError {
+	return &APIError{Status: status, Code: code, Description: description}
+}
+
+func (e *APIError) Error() string {
+	if e.Cause != nil {
+		return e.Description + ": " + e.Cause.Error()
+	}
+	return e.Description
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Cause
+}
+
+// Wrap returns a copy of the error caused by an internal error
+func (e *APIError) Wrap(cause error) *APIError {
+	wrapped := *e
+	wrapped.Cause = cause
+	return &wrapped
+}
+
+// WithDetails returns a copy of the error with the details of the invalid fields
+func (e *APIError) WithDetails(details ...FieldError) *APIError {
+	withDetails := *e
+	withDetails.Details = append(append([]FieldError{}, e.Details...), details...)
+	return &withDetails
+}
+
+// WithRetryAfter returns a copy of the error telling the client to wait for the delay before trying again
+func (e *APIError) WithRetryAfter(delay time.Duration) *APIError {
+	withRetryAfter := *e
+	withRetryAfter.RetryAfter = delay
+	return &withRetryAfter
+}
+
+// Is tells if the error has the same code as the target, so that errors.Is works with the errors of this package
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// From returns the API error of an error, an internal server error wrapping it if it is not an API error
+func From(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return InternalServerError.Wrap(err)
+}
+
+// InvalidBody returns the error of a request body which can not be parsed
+func InvalidBody(err error) *APIError {
+	return New(BadRequest.Status, errorCodes.BadRequest, err.Error())
+}
@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors/errorCodes"
+	"goapi/errors/errorDesc"
+)
+
+// The errors sent by the API, use Wrap to keep their internal cause
+var (
+	InternalServerError = New(fiber.StatusInternalServerError, errorCodes.InternalServerError, errorDesc.InternalServerError)
+	DatabaseTimeout     = New(fiber.StatusGatewayTimeout, errorCodes.DatabaseTimeout, errorDesc.DatabaseTimeout)
+
+	BadRequest                  = New(fiber.StatusBadRequest, errorCodes.BadRequest, errorDesc.BadRequest)
+	ResourceNotFound            = New(fiber.StatusNotFound, errorCodes.ResourceNotFound, errorDesc.ResourceNotFound)
+	Forbidden                   = New(fiber.StatusForbidden, errorCodes.Forbidden, errorDesc.Forbidden)
+	RequiredFieldEmpty          = New(fiber.StatusBadRequest, errorCodes.RequiredFieldEmpty, errorDesc.RequiredFieldEmpty)
+	ValidationFailed            = New(fiber.StatusBadRequest, errorCodes.ValidationFailed, errorDesc.ValidationFailed)
+	EmailAddressAlreadyExists   = New(fiber.StatusConflict, errorCodes.EmailAddressAlreadyExists, errorDesc.EmailAddressAlreadyExists)
+	EmailAddressDomainForbidden = New(fiber.StatusNotAcceptable, errorCodes.EmailAddressDomainForbidden, errorDesc.EmailAddressDomainForbidden)
+	RoomsLimitReached           = New(fiber.StatusConflict, errorCodes.RoomsLimitReached, errorDesc.RoomsLimitReached)
+	HouseMemberAlreadyExists    = New(fiber.StatusConflict, errorCodes.HouseMemberAlreadyExists, errorDesc.HouseMemberAlreadyExists)
+	HouseMembersLimitReached    = New(fiber.StatusConflict, errorCodes.HouseMembersLimitReached, errorDesc.HouseMembersLimitReached)
+	HouseOwnerCannotChange      = New(fiber.StatusConflict, errorCodes.HouseOwnerCannotChange, errorDesc.HouseOwnerCannotChange)
+	InvitationTokenInvalid      = New(fiber.StatusBadRequest, errorCodes.InvitationTokenInvalid, errorDesc.InvitationTokenInvalid)
+	InvitationEmailMismatch     = New(fiber.StatusForbidden, errorCodes.InvitationEmailMismatch, errorDesc.InvitationEmailMismatch)
+	OwnershipTransferRequired   = New(fiber.StatusConflict, errorCodes.OwnershipTransferRequired, errorDesc.OwnershipTransferRequired)
+	OwnershipTransferToOwner    = New(fiber.StatusBadRequest, errorCodes.OwnershipTransferToOwner, errorDesc.OwnershipTransferToOwner)
+	OwnershipTransferNotPending = New(fiber.StatusConflict, errorCodes.OwnershipTransferNotPending, errorDesc.OwnershipTransferNotPending)
+	CursorInvalid               = New(fiber.StatusBadRequest, errorCodes.CursorInvalid, errorDesc.CursorInvalid)
+
+	JWTMissing          = New(fiber.StatusUnauthorized, errorCodes.JWTMissing, errorDesc.NoTokenWereProvided)
+	JWTNotBearer        = New(fiber.StatusUnauthorized, errorCodes.JWTMissing, errorDesc.AuthorizationHeaderMustBeBearerToken)
+	JWTInvalid          = New(fiber.StatusUnauthorized, errorCodes.JWTInvalid, errorDesc.JWTInvalid)
+	JWTExpired          = New(fiber.StatusUnauthorized, errorCodes.JWTExpired, errorDesc.JWTExpired)
+	JWTIsStillValid     = New(fiber.StatusBadRequest, errorCodes.JWTIsStillValid, errorDesc.JWTIsStillValid)
+	JWTRevoked          = New(fiber.StatusUnauthorized, errorCodes.JWTRevoked, errorDesc.JWTRevoked)
+	RefreshTokenInvalid = New(fiber.StatusUnauthorized, errorCodes.RefreshTokenInvalid, errorDesc.RefreshTokenInvalid)
+	RefreshTokenExpired = New(fiber.StatusUnauthorized, errorCodes.RefreshTokenExpired, errorDesc.RefreshTokenExpired)
+	RefreshTokenReused  = New(fiber.StatusUnauthorized, errorCodes.RefreshTokenReused, errorDesc.RefreshTokenReused)
+
+	CredentialDoesNotMatch = New(fiber.StatusUnauthorized, errorCodes.CredentialDoesNotMatch, errorDesc.CredentialDoesNotMatch)
+	LoginThrottled         = New(fiber.StatusTooManyRequests, errorCodes.LoginThrottled, errorDesc.LoginThrottled)
+	LoginTemporarilyLocked = New(fiber.StatusTooManyRequests, errorCodes.LoginTemporarilyLocked, errorDesc.LoginTemporarilyLocked)
+	AccountDisabled        = New(fiber.StatusForbidden, errorCodes.AccountDisabled, errorDesc.AccountDisabled)
+
+	MFATokenInvalid   = New(fiber.StatusUnauthorized, errorCodes.MFATokenInvalid, errorDesc.MFATokenInvalid)
+	MFACodeInvalid    = New(fiber.StatusUnauthorized, errorCodes.MFACodeInvalid, errorDesc.MFACodeInvalid)
+	MFAAlreadyEnabled = New(fiber.StatusConflict, errorCodes.MFAAlreadyEnabled, errorDesc.MFAAlreadyEnabled)
+	MFANotEnabled     = New(fiber.StatusBadRequest, errorCodes.MFANotEnabled, errorDesc.MFANotEnabled)
+	MFANotEnrolled    = New(fiber.StatusBadRequest, errorCodes.MFANotEnrolled, errorDesc.MFANotEnrolled)
+
+	PasswordResetTokenInvalid = New(fiber.StatusBadRequest, errorCodes.PasswordResetTokenInvalid, errorDesc.PasswordResetTokenInvalid)
+
+	EmailVerificationTokenInvalid = New(fiber.StatusBadRequest, errorCodes.EmailVerificationTokenInvalid, errorDesc.EmailVerificationTokenInvalid)
+	EmailVerificationThrottled    = New(fiber.StatusTooManyRequests, errorCodes.EmailVerificationThrottled, errorDesc.EmailVerificationThrottled)
+	EmailAlreadyVerified          = New(fiber.StatusBadRequest, errorCodes.EmailAlreadyVerified, errorDesc.EmailAlreadyVerified)
+	EmailNotVerified              = New(fiber.StatusForbidden, errorCodes.EmailNotVerified, errorDesc.EmailNotVerified)
+)
@@ -2,15 +2,56 @@ package errorCodes
 
 const Unknown = "unknown"
 const InternalServerError = "internalServerError"
+const DatabaseTimeout = "databaseTimeout"
 
 const BadRequest = "badRequest"
 const ResourceNotFound = "resourceNotFound"
+const Forbidden = "forbidden"
 const RequiredFieldEmpty = "requiredFieldEmpty"
+const ValidationFailed = "validationFailed"
+const FieldTooShort = "fieldTooShort"
+const FieldTooLong = "fieldTooLong"
+const FieldTooSmall = "fieldTooSmall"
+const FieldTooLarge = "fieldTooLarge"
+const FieldNotAllowed = "fieldNotAllowed"
+const FieldInvalid = "fieldInvalid"
+const EmailAddressInvalid = "emailAddressInvalid"
 const EmailAddressAlreadyExists = "emailAddressAlreadyExists"
 const EmailAddressDomainForbidden = "emailAddressDomainForbidden"
+const RoomsLimitReached = "roomsLimitReached"
+const HouseMemberAlreadyExists = "houseMemberAlreadyExists"
+const HouseMembersLimitReached = "houseMembersLimitReached"
+const HouseOwnerCannotChange = "houseOwnerCannotChange"
+const InvitationTokenInvalid = "invitationTokenInvalid"
+const InvitationEmailMismatch = "invitationEmailMismatch"
+const OwnershipTransferRequired = "ownershipTransferRequired"
+const OwnershipTransferToOwner = "ownershipTransferToOwner"
+const OwnershipTransferNotPending = "ownershipTransferNotPending"
+const CursorInvalid = "cursorInvalid"
 
-const JWTExpiredCanBeRefreshed = "jwtExpiredCanBeRefreshed"
-const JWTExpiredCannotBeRefreshed = "jwtExpiredCannotBeRefreshed"
+const JWTMissing = "jwtMissing"
+const JWTInvalid = "jwtInvalid"
+const JWTExpired = "jwtExpired"
 const JWTIsStillValid = "jwtIsStillValid"
+const JWTRevoked = "jwtRevoked"
+const RefreshTokenInvalid = "refreshTokenInvalid"
+const RefreshTokenExpired = "refreshTokenExpired"
+const RefreshTokenReused = "refreshTokenReused"
 
-const CredentialDoesNotMatch = "credentialDoesNotMatch"
\ No newline at end of file
+const CredentialDoesNotMatch = "credentialDoesNotMatch"
+const LoginThrottled = "loginThrottled"
+const LoginTemporarilyLocked = "loginTemporarilyLocked"
+const AccountDisabled = "accountDisabled"
+
+const MFATokenInvalid = "mfaTokenInvalid"
+const MFACodeInvalid = "mfaCodeInvalid"
+const MFAAlreadyEnabled = "mfaAlreadyEnabled"
+const MFANotEnabled = "mfaNotEnabled"
+const MFANotEnrolled = "mfaNotEnrolled"
+
+const PasswordResetTokenInvalid = "passwordResetTokenInvalid"
+
+const EmailVerificationTokenInvalid = "emailVerificationTokenInvalid"
+const EmailVerificationThrottled = "emailVerificationThrottled"
+const EmailAlreadyVerified = "emailAlreadyVerified"
+const EmailNotVerified = "emailNotVerified"
@@ -4,18 +4,65 @@ package errorDesc
 
 const Unknown = "unknown error, operation has not been finished"
 const InternalServerError = "internal server error"
+const BadRequest = "the request is not valid"
+const DatabaseTimeout = "the database did not answer in time, try again later"
 
 const ResourceNotFound = "resource not found"
+const Forbidden = "you are not allowed to access this resource"
 const RequiredFieldEmpty = "at least of the required fields is empty, maybe you mistyped it, or left it empty but it must be filled with something to be inserted in database"
+const ValidationFailed = "some fields are not valid, see the details"
+
+// Descriptions of the invalid fields, in the details of a validation error, formatted with the limit of the rule
+const FieldRequired = "is required"
+const FieldTooShort = "must be at least %v characters long"
+const FieldTooLong = "must be at most %v characters long"
+const FieldTooFewItems = "must have at least %v items"
+const FieldTooManyItems = "must have at most %v items"
+const FieldTooSmall = "must be at least %v"
+const FieldTooLarge = "must be at most %v"
+const FieldNotAllowed = "must be one of: %v"
+const FieldNotAnInteger = "must be an integer"
+const FieldNotABoolean = "must be true or false"
+const EmailAddressInvalid = "must be a valid email address"
 
 const EmailAddressAlreadyExists = "email address already exists"
 const EmailAddressDomainForbidden = "email address domain is forbidden"
+const RoomsLimitReached = "this house already has the maximum number of rooms"
+const HouseMemberAlreadyExists = "this user is already a member of this house"
+const HouseMembersLimitReached = "this house already has the maximum number of members"
+const HouseOwnerCannotChange = "the owner of a house can not be removed or given another role"
+const InvitationTokenInvalid = "this invitation is invalid, expired or has already been answered"
+const InvitationEmailMismatch = "this invitation was sent to another email address"
+const OwnershipTransferRequired = "the owner of a house can only be changed with an ownership transfer"
+const OwnershipTransferToOwner = "a house can not be transferred to its owner"
+const OwnershipTransferNotPending = "there is no pending ownership transfer of this house to this user"
+const CursorInvalid = "this cursor is not valid for this list, start again from the first page"
 
 const NoTokenWereProvided = "no token were provided"
 const AuthorizationHeaderMustBeBearerToken = "authorization header format must be Bearer {token}"
 const TokenSignatureIsNotValid = "token signature is not valid"
-const JWTExpiredCanBeRefreshed = "this jwt is expired but can be refreshed"
-const JWTExpiredCannotBeRefreshed = "this jwt is expired and cannot be refreshed, you must login"
+const JWTInvalid = "this jwt is not valid, you must login"
+const JWTExpired = "this jwt is expired, get a new one with the refresh token or login again"
 const JWTIsStillValid = "this token is still valid and cannot be refreshed yet"
+const JWTRevoked = "this jwt has been revoked, you must login"
+const RefreshTokenInvalid = "this refresh token is invalid or has been revoked, you must login"
+const RefreshTokenExpired = "this refresh token is expired, you must login"
+const RefreshTokenReused = "this refresh token has already been used, all the tokens of this session have been revoked"
 
 const CredentialDoesNotMatch = "invalid email address or password"
+const LoginThrottled = "too many failed login attempts, wait before trying again"
+const LoginTemporarilyLocked = "too many failed login attempts, login is temporarily locked"
+const AccountDisabled = "this account has been disabled"
+
+const MFATokenInvalid = "this mfa token is invalid or expired, you must login again"
+const MFACodeInvalid = "invalid or already used authentication code"
+const MFAAlreadyEnabled = "two-factor authentication is already enabled"
+const MFANotEnabled = "two-factor authentication is not enabled"
+const MFANotEnrolled = "two-factor authentication enrollment must be started first"
+
+const PasswordResetTokenInvalid = "this password reset token is invalid, expired or has already been used"
+
+const EmailVerificationTokenInvalid = "this email verification token is invalid, expired or has already been used"
+const EmailVerificationThrottled = "a verification email has been sent recently, wait before asking for a new one"
+const EmailAlreadyVerified = "this email address is already verified"
+const EmailNotVerified = "the email address of this account must be verified first"
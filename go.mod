@@ -5,8 +5,6 @@ go 1.14
 require (
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gofiber/fiber v1.9.3
-	github.com/gofiber/jwt v0.0.6
-	github.com/gofiber/logger v0.0.5
 	github.com/stretchr/testify v1.4.0 // indirect
 	go.mongodb.org/mongo-driver v1.3.2
 	golang.org/x/crypto v0.0.0-20200429183012-4b2356b1ed79
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level of its name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for i, levelName := range levelNames {
+		if strings.ToLower(name) == levelName {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+// Logger writes one JSON object per line, with the time, the level, the message and the fields
+// Fields are given as key-value pairs, e.g. logger.Info("mail sent", "to", address)
+type Logger struct {
+	out    *output
+	fields []interface{} // added to every line, see With
+}
+
+// The writer and the level, shared by a logger and the loggers returned by its With
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// New returns a logger writing the lines of the given level and above
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{out: &output{w: w, level: level}}
+}
+
+// With returns a logger adding the given key-value pairs to every line
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return &Logger{out: l.out, fields: append(append([]interface{}{}, l.fields...), keyvals...)}
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) { l.log(LevelDebug, msg, keyvals) }
+func (l *Logger) Info(msg string, keyvals ...interface{})  { l.log(LevelInfo, msg, keyvals) }
+func (l *Logger) Warn(msg string, keyvals ...interface{})  { l.log(LevelWarn, msg, keyvals) }
+func (l *Logger) Error(msg string, keyvals ...interface{}) { l.log(LevelError, msg, keyvals) }
+
+// Fatal writes an error line then exits the program
+func (l *Logger) Fatal(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+	os.Exit(1)
+}
+
+// Log writes a line of the given level, e.g. a level depending on a status code
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	l.log(level, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if level < l.out.level {
+		return
+	}
+	line := map[string]interface{}{}
+	for _, pairs := range [][]interface{}{l.fields, keyvals} {
+		for i := 0; i < len(pairs); i += 2 {
+			key := fmt.Sprint(pairs[i])
+			var value interface{} = "MISSING"
+			if i+1 < len(pairs) {
+				value = pairs[i+1]
+			}
+			if err, ok := value.(error); ok { // errors are not marshalled with their message
+				value = err.Error()
+			}
+			line[key] = value
+		}
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = level.String()
+	line["msg"] = msg
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		encoded, _ = json.Marshal(map[string]interface{}{"time": line["time"], "level": line["level"], "msg": msg, "logError": err.Error()})
+	}
+	l.out.mu.Lock()
+	_, _ = l.out.w.Write(append(encoded, '\n'))
+	l.out.mu.Unlock()
+}
+
+var defaultMu sync.RWMutex
+var defaultLogger = New(os.Stderr, LevelInfo)
+
+// SetDefault sets the logger used by the package functions and by FromContext without logger
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defaultLogger = l
+	defaultMu.Unlock()
+}
+
+// Default returns the logger set by SetDefault, writing the info lines on stderr until then
+func Default() *Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+func Debug(msg string, keyvals ...interface{}) { Default().log(LevelDebug, msg, keyvals) }
+func Info(msg string, keyvals ...interface{})  { Default().log(LevelInfo, msg, keyvals) }
+func Warn(msg string, keyvals ...interface{})  { Default().log(LevelWarn, msg, keyvals) }
+func Error(msg string, keyvals ...interface{}) { Default().log(LevelError, msg, keyvals) }
+
+// Fatal writes an error line with the default logger then exits the program
+func Fatal(msg string, keyvals ...interface{}) {
+	Default().log(LevelError, msg, keyvals)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a context carrying the logger, e.g. a logger with the request ID
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of the context, the default logger if there is none
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return Default()
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"goapi/logging"
+	"sync"
+)
+
+// NewAsyncMailer returns a mailer which queues the messages and sends them in background with the given mailer
+// Requests do not wait for the SMTP server anymore, the sending errors are only written in the logs
+// Close must be called on shutdown so that the queued messages are sent
+func NewAsyncMailer(mailer Mailer, queueSize int) *AsyncMailer {
+	m := &AsyncMailer{
+		mailer: mailer,
+		queue:  make(chan Message, queueSize),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+type AsyncMailer struct {
+	mailer Mailer
+	queue  chan Message
+	done   chan struct{} // closed when every queued message is sent
+	mu     sync.RWMutex
+	closed bool
+}
+
+// Queues the message, it fails only if the queue is full or the mailer closed
+func (m *AsyncMailer) Send(message Message) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return errors.New("mailer is closed")
+	}
+	select {
+	case m.queue <- message:
+		return nil
+	default:
+		return errors.New("mail queue is full")
+	}
+}
+
+// Close stops accepting messages and waits until the queued ones are sent, or until the context is done
+func (m *AsyncMailer) Close(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sends the queued messages until the queue is closed
+func (m *AsyncMailer) run() {
+	for message := range m.queue {
+		if err := m.mailer.Send(message); err != nil {
+			logging.Error("mail not sent", "subject", message.Subject, "error", err)
+		}
+	}
+	close(m.done)
+}
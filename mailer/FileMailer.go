@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// NewFileMailer returns a mailer writing each email as a .eml file in the directory
+// Useful for local development and tests, the files can be opened with any mail client
+func NewFileMailer(directory string, from string) Mailer {
+	return &fileMailer{
+		directory: directory,
+		from:      from,
+	}
+}
+
+type fileMailer struct {
+	directory string
+	from      string
+}
+
+// Writes the message in a new file, named after the current time so they are listed in order
+func (m fileMailer) Send(message Message) error {
+	err := os.MkdirAll(m.directory, 0755)
+	if err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000000000") + "-" + randomID() + ".eml"
+	return ioutil.WriteFile(filepath.Join(m.directory, name), buildRawEmail(m.from, message), 0644)
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import "goapi/logging"
+
+// NewLogMailer returns a mailer which only writes the emails in the logs
+// Useful for development, no email is really sent
+// Their body is not written, as it holds the tokens of the links, use the file mailer to read them
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+type logMailer struct{}
+
+// Writes the recipient, the subject and the template of the message in the logs
+func (m logMailer) Send(message Message) error {
+	logging.Info("mail", "to", message.To, "subject", message.Subject, "template", message.Template)
+	return nil
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message is an email to send to a single recipient
+type Message struct {
+	To       string
+	Subject  string
+	Body     string
+	Template string // name of the template it was built from, if any
+}
+
+// Mailer sends the emails of the application
+// Services only depend on this interface, the implementation is chosen in main
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Builds the raw RFC 5322 email of a message, as sent by SMTP or written in a .eml file
+// The body is sent as UTF-8 plain text
+func buildRawEmail(from string, message Message) []byte {
+	var raw bytes.Buffer
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at != -1 {
+		domain = strings.Trim(from[at+1:], "> ")
+	}
+	headers := []string{
+		"From: " + from,
+		"To: " + message.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + randomID() + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	for _, header := range headers {
+		raw.WriteString(header + "\r\n")
+	}
+	raw.WriteString("\r\n")
+	raw.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return raw.Bytes()
+}
+
+// Generates a random id, used for message ids and file names
+func randomID() string {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// NewSMTPMailer returns a mailer sending the emails through a SMTP server
+// Authentication is only used when an username is given, STARTTLS is used if the server supports it
+func NewSMTPMailer(host string, port int, username string, password string, from string) Mailer {
+	return &smtpMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+type smtpMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// Sends the message to the SMTP server
+func (m smtpMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	address := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	return smtp.SendMail(address, auth, m.from, []string{message.To}, buildRawEmail(m.from, message))
+}
@@ -0,0 +1,133 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"goapi/models"
+	"strings"
+	"text/template"
+)
+
+// Names of the message templates
+const PasswordResetTemplate = "passwordReset"
+const EmailVerificationTemplate = "emailVerification"
+const HouseInvitationTemplate = "houseInvitation"
+const HouseInvitationAcceptedTemplate = "houseInvitationAccepted"
+const OwnershipTransferTemplate = "ownershipTransfer"
+
+// Language used when the language of the user has no translation
+const DefaultLanguage = "en"
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// Templates by name then by language
+// Templates receive the data given to NewMessage, plus the recipient as "User"
+var templates = map[string]map[string]messageTemplate{
+	PasswordResetTemplate: {
+		"en": newMessageTemplate("Reset your password",
+			"Hello {{.User.FirstName}},\n\n"+
+				"Follow this link to choose a new password, it is valid during {{.ValidMinutes}} minutes:\n"+
+				"{{.Link}}\n\n"+
+				"If you did not ask for it, you can ignore this email.\n"),
+		"fr": newMessageTemplate("Réinitialisez votre mot de passe",
+			"Bonjour {{.User.FirstName}},\n\n"+
+				"Suivez ce lien pour choisir un nouveau mot de passe, il est valable pendant {{.ValidMinutes}} minutes :\n"+
+				"{{.Link}}\n\n"+
+				"Si vous n'êtes pas à l'origine de cette demande, vous pouvez ignorer cet email.\n"),
+	},
+	EmailVerificationTemplate: {
+		"en": newMessageTemplate("Verify your email address",
+			"Hello {{.User.FirstName}},\n\n"+
+				"Follow this link to verify your email address, it is valid during {{.ValidHours}} hours:\n"+
+				"{{.Link}}\n"),
+		"fr": newMessageTemplate("Vérifiez votre adresse email",
+			"Bonjour {{.User.FirstName}},\n\n"+
+				"Suivez ce lien pour vérifier votre adresse email, il est valable pendant {{.ValidHours}} heures :\n"+
+				"{{.Link}}\n"),
+	},
+	// The recipient may not have an account yet, then only his email address is known
+	HouseInvitationTemplate: {
+		"en": newMessageTemplate("{{.Inviter.FirstName}} invites you to {{.House.Name}}",
+			"Hello{{with .User.FirstName}} {{.}}{{end}},\n\n"+
+				"{{.Inviter.FirstName}} {{.Inviter.LastName}} invites you to join the house {{.House.Name}} as {{.Role}}.\n"+
+				"Follow this link to accept or decline, it is valid during {{.ValidHours}} hours:\n"+
+				"{{.Link}}\n\n"+
+				"If you do not have an account yet, register with this email address first.\n"),
+		"fr": newMessageTemplate("{{.Inviter.FirstName}} vous invite dans {{.House.Name}}",
+			"Bonjour{{with .User.FirstName}} {{.}}{{end}},\n\n"+
+				"{{.Inviter.FirstName}} {{.Inviter.LastName}} vous invite à rejoindre la maison {{.House.Name}} en tant que {{.Role}}.\n"+
+				"Suivez ce lien pour accepter ou refuser, il est valable pendant {{.ValidHours}} heures :\n"+
+				"{{.Link}}\n\n"+
+				"Si vous n'avez pas encore de compte, inscrivez-vous d'abord avec cette adresse email.\n"),
+	},
+	HouseInvitationAcceptedTemplate: {
+		"en": newMessageTemplate("{{.Member.FirstName}} joined {{.House.Name}}",
+			"Hello {{.User.FirstName}},\n\n"+
+				"{{.Member.FirstName}} {{.Member.LastName}} accepted your invitation and is now {{.Role}} of the house {{.House.Name}}.\n"),
+		"fr": newMessageTemplate("{{.Member.FirstName}} a rejoint {{.House.Name}}",
+			"Bonjour {{.User.FirstName}},\n\n"+
+				"{{.Member.FirstName}} {{.Member.LastName}} a accepté votre invitation et est maintenant {{.Role}} de la maison {{.House.Name}}.\n"),
+	},
+	OwnershipTransferTemplate: {
+		"en": newMessageTemplate("{{.Owner.FirstName}} wants to give you {{.House.Name}}",
+			"Hello {{.User.FirstName}},\n\n"+
+				"{{.Owner.FirstName}} {{.Owner.LastName}} wants to make you the owner of the house {{.House.Name}}.\n"+
+				"Follow this link to accept or decline, it is valid during {{.ValidHours}} hours:\n"+
+				"{{.Link}}\n"),
+		"fr": newMessageTemplate("{{.Owner.FirstName}} veut vous confier {{.House.Name}}",
+			"Bonjour {{.User.FirstName}},\n\n"+
+				"{{.Owner.FirstName}} {{.Owner.LastName}} veut faire de vous le propriétaire de la maison {{.House.Name}}.\n"+
+				"Suivez ce lien pour accepter ou refuser, il est valable pendant {{.ValidHours}} heures :\n"+
+				"{{.Link}}\n"),
+	},
+}
+
+// Parses a template, panics if it is not valid as templates are written in this file
+func newMessageTemplate(subject string, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body)),
+	}
+}
+
+// NewMessage builds the message of a template for an user
+// The language of the user selects the translation, the default language is used if there is none
+func NewMessage(templateName string, user models.User, data map[string]interface{}) (Message, error) {
+	translations, ok := templates[templateName]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %s", templateName)
+	}
+	tmpl, ok := translations[languageCode(user.Language)]
+	if !ok {
+		tmpl = translations[DefaultLanguage]
+	}
+
+	values := map[string]interface{}{}
+	for key, value := range data {
+		values[key] = value
+	}
+	values["User"] = user
+
+	var subject, body bytes.Buffer
+	err := tmpl.subject.Execute(&subject, values)
+	if err != nil {
+		return Message{}, err
+	}
+	err = tmpl.body.Execute(&body, values)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: user.Email, Subject: subject.String(), Body: body.String(), Template: templateName}, nil
+}
+
+// Keeps the primary language of a language tag, "fr-FR" or "fr_FR" gives "fr"
+func languageCode(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if i := strings.IndexAny(language, "-_"); i != -1 {
+		language = language[:i]
+	}
+	return language
+}
@@ -2,50 +2,97 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gofiber/fiber"
-	"github.com/gofiber/jwt"
-	"github.com/gofiber/logger"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"goapi/config"
 	"goapi/controllers"
+	"goapi/logging"
+	"goapi/mailer"
+	"goapi/metrics"
+	"goapi/middlewares"
+	"goapi/models"
 	"goapi/repositories"
 	"goapi/services"
-	"gopkg.in/yaml.v2"
-	"io/ioutil"
-	"log"
+	"goapi/signing"
+	"goapi/tracing"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 )
 
-// todo user email domain verification
-// todo reset user password (forgotten)
-
 // todo admin web page
 
 // todo docstring + warning/typo cleaning
 
-var database = mongoDBConnect()
-
 func main() {
-	app := fiber.New()
-	app.Use(logger.New())
+	// Loads the config from the config file, the environment variables and the flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	config.Current = cfg
+	logLevel, _ := logging.ParseLevel(config.Current.LogLevel)
+	logging.SetDefault(logging.New(os.Stderr, logLevel))
+	database := mongoDBConnect()
+	tracer := newTracer()
+
+	app := fiber.New(&fiber.Settings{
+		ReadTimeout:           time.Second * time.Duration(config.Current.ReadTimeoutInSeconds),
+		DisableStartupMessage: true, // only JSON lines are written, see LogRequests
+	})
+	app.Use(middlewares.Trace())
+	app.Use(middlewares.LogRequests())
+	app.Use(middlewares.RecordMetrics())
+	app.Use(middlewares.HandleErrors())
 
 	// Sets MongoDB collections
 	userCollection := database.Collection("users")
 	houseCollection := database.Collection("houses")
+	refreshTokenCollection := database.Collection("refresh_tokens")
+	revocationCollection := database.Collection("revocations")
+	passwordResetCollection := database.Collection("password_resets")
+	emailVerificationCollection := database.Collection("email_verifications")
+	loginAttemptCollection := database.Collection("login_attempts")
+	invitationCollection := database.Collection("invitations")
+	houseEventCollection := database.Collection("house_events")
 	// Sets repositories
 	userRepo := repositories.NewUserRepository(userCollection)
 	houseRepo := repositories.NewHouseRepository(houseCollection)
+	refreshTokenRepo := repositories.NewRefreshTokenRepository(refreshTokenCollection)
+	revocationRepo := repositories.NewRevocationRepository(revocationCollection)
+	passwordResetRepo := repositories.NewPasswordResetRepository(passwordResetCollection)
+	emailVerificationRepo := repositories.NewEmailVerificationRepository(emailVerificationCollection)
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(loginAttemptCollection)
+	invitationRepo := repositories.NewInvitationRepository(invitationCollection)
+	houseEventRepo := repositories.NewHouseEventRepository(houseEventCollection)
+	databaseRepo := repositories.NewDatabaseRepository(database)
+	// Sets mailer and JWT keys
+	appMailer := mailer.NewAsyncMailer(newMailer(), config.Current.MailerQueueSize)
+	keySet := newKeySet()
 	// Sets services
-	userService := services.NewUserService(userRepo)
-	houseService := services.NewHouseService(houseRepo, userRepo)
-	authService := services.NewAuthService(userRepo)
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, revocationRepo, passwordResetRepo, emailVerificationRepo, loginAttemptRepo, keySet, appMailer)
+	userService := services.NewUserService(userRepo, authService)
+	houseService := services.NewHouseService(houseRepo, userRepo, houseEventRepo, invitationRepo, appMailer)
+	invitationService := services.NewInvitationService(invitationRepo, houseRepo, userRepo, appMailer)
+	mfaService := services.NewMFAService(userRepo, loginAttemptRepo, authService, time.Now)
+	healthService := services.NewHealthService(databaseRepo, time.Second*time.Duration(config.Current.HealthPingTimeoutInSeconds), "indexes", "migrations")
 	// Sets controllers
 	userController := controllers.UserController{UserService: userService, AuthService: authService}
 	houseController := controllers.HouseController{Service: houseService}
-	authController := controllers.AuthController{AuthService: authService, UserService: userService}
+	roomController := controllers.RoomController{Service: houseService}
+	memberController := controllers.MemberController{Service: houseService}
+	invitationController := controllers.InvitationController{Service: invitationService}
+	transferController := controllers.TransferController{Service: houseService}
+	jwksController := controllers.JWKSController{KeySet: keySet}
+	healthController := controllers.HealthController{Service: healthService}
+	metricsController := controllers.MetricsController{Registry: metrics.DefaultRegistry}
+	authController := controllers.AuthController{AuthService: authService, UserService: userService, MFAService: mfaService}
+
+	// Ensures the indexes and applies the migrations in background, the server is ready once they are done
+	go setupDatabase(healthService, databaseRepo, []indexesEnsurer{refreshTokenRepo, revocationRepo, passwordResetRepo, emailVerificationRepo, loginAttemptRepo, houseRepo, invitationRepo, houseEventRepo})
 
 	// Set the first groups for routes
 	api := app.Group("/v" + strconv.Itoa(config.CurrentAPIVersion))
@@ -53,72 +100,213 @@ func main() {
 	users := api.Group("/users")
 	houses := api.Group("/houses")
 	auth := api.Group("/auth")
+	invitations := api.Group("/invitations")
 
 	// Unauthenticated routes
-	users.Post("", userController.Post)       // Register route
-	auth.Post("/login", authController.Login) // todo waiting time to prevent brute-force
-	auth.Get("/refreshjwt", authController.RefreshJWT)
+	app.Get("/healthz", healthController.Liveness)
+	app.Get("/readyz", healthController.Readiness)
+	if config.Current.MetricsEnabled {
+		app.Get("/metrics", metricsController.Get)
+	}
+	app.Get("/.well-known/jwks.json", jwksController.Get)
+	users.Post("", userController.Post) // Register route
+	auth.Post("/login", authController.Login)
+	auth.Post("/refresh", authController.Refresh)
+	auth.Post("/password/forgot", authController.ForgotPassword)
+	auth.Post("/password/reset", authController.ResetPassword)
+	auth.Get("/verify-email", authController.VerifyEmail)
+	auth.Post("/mfa/verify", authController.VerifyMFA)
+	invitations.Post("/decline", invitationController.Decline)
 
-	// JWT Middleware: Routes declared below will require a valid JWT
-	app.Use(jwtware.New(jwtware.Config{
-		SigningKey:    []byte(config.HmacSampleSecret),
-		SigningMethod: jwt.SigningMethodHS512.Name,
-	})) // TODO handler to sent error in json format + TODO handler to check if not disabled user
+	// Auth Middleware: Routes declared below will require a valid JWT of an enabled user
+	app.Use(middlewares.Authenticate(authService))
 
 	// Restricted routes requiring a valid JWT
+	auth.Post("/logout", authController.Logout)
+	auth.Post("/logout-all", authController.LogoutAll)
+	auth.Post("/verify-email/resend", authController.ResendEmailVerification)
+	auth.Post("/mfa/enroll", authController.EnrollMFA)
+	auth.Post("/mfa/confirm", authController.ConfirmMFA)
+	auth.Post("/mfa/disable", authController.DisableMFA)
+	auth.Post("/mfa/recovery-codes", authController.RegenerateRecoveryCodes)
+
 	users.Get("/:id", userController.GetByID)
 	users.Patch("/:id", userController.PatchBy)
 	users.Delete("/:id", userController.DeleteBy)
 
+	if config.Current.VerifiedEmailRequiredForHouses {
+		houses.Use(middlewares.RequireVerifiedEmail(authService))
+	}
 	houses.Post("", houseController.Post)
 	houses.Get("/:id", houseController.GetByID)
 	houses.Get("/ofUser/:id", houseController.GetByUserID)
 	houses.Patch("/:id", houseController.PatchBy)
 	houses.Delete("/:id", houseController.DeleteBy)
+	houses.Get("/:id/rooms", roomController.GetAll)
+	houses.Post("/:id/rooms", roomController.Post)
+	houses.Get("/:id/rooms/:roomId", roomController.GetByID)
+	houses.Patch("/:id/rooms/:roomId", roomController.PatchBy)
+	houses.Delete("/:id/rooms/:roomId", roomController.DeleteBy)
+	houses.Get("/:id/members", memberController.GetAll)
+	houses.Post("/:id/members", memberController.Post)
+	houses.Patch("/:id/members/:userId", memberController.PatchBy)
+	houses.Delete("/:id/members/:userId", memberController.DeleteBy)
+	houses.Get("/:id/invitations", invitationController.GetAll)
+	houses.Post("/:id/invitations", invitationController.Post)
+	houses.Delete("/:id/invitations/:invitationId", invitationController.DeleteBy)
+	houses.Post("/:id/transfer", transferController.Post)
+	houses.Post("/:id/transfer/accept", transferController.Accept)
+	houses.Delete("/:id/transfer", transferController.DeleteBy)
+	houses.Get("/:id/history", houseController.GetHistory)
+	invitations.Post("/accept", invitationController.Accept)
+
+	// Admin routes requiring a valid JWT with the admin role
+	admin := api.Group("/admin", middlewares.RequireRoles(models.RoleAdmin))
+	admin.Get("/users", userController.GetAll)
+	admin.Patch("/users/:id/disable", userController.Disable)
+	admin.Patch("/users/:id/enable", userController.Enable)
+	admin.Patch("/users/:id/unlock", userController.Unlock)
+	admin.Put("/users/:id/roles", userController.PutRoles)
+	admin.Get("/houses", houseController.GetAll)
 
-	// Routes only activated for development
-	if config.DevStatus {
-		users.Get("", userController.GetAll)
-		houses.Get("", houseController.GetAll)
+	// Serves until SIGINT or SIGTERM, then shuts down gracefully
+	listenErr := make(chan error, 1)
+	go func() {
+		logging.Info("listening", "port", config.Current.Port)
+		listenErr <- app.Listen(config.Current.Port)
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-listenErr:
+		logging.Error("server stopped", "error", err)
+	case sig := <-stop:
+		logging.Info("shutting down", "signal", sig.String())
+		shutdown(app)
 	}
+	shutdownBackground(appMailer, tracer, database.Client())
+}
 
-	err := app.Listen(5000)
-	if err != nil {
-		fmt.Print(err)
+// Stops accepting connections and waits for the in-flight requests, up to the shutdown timeout set in the config
+func shutdown(app *fiber.App) {
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			logging.Error("shutdown failed", "error", err)
+		}
+	case <-time.After(time.Second * time.Duration(config.Current.ShutdownTimeoutInSeconds)):
+		logging.Warn("shutdown timeout, remaining requests are dropped")
 	}
 }
 
-func mongoDBConnect() *mongo.Database {
-	// Load database info from yaml file
-	type database struct {
-		URI  string `yaml:"DatabaseURI"`
-		Name string `yaml:"DatabaseName"`
+// Sends the queued mails and spans and disconnects from MongoDB, once no request uses them anymore
+func shutdownBackground(appMailer *mailer.AsyncMailer, tracer *tracing.Tracer, client *mongo.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(config.Current.ShutdownTimeoutInSeconds))
+	defer cancel()
+	if err := appMailer.Close(ctx); err != nil {
+		logging.Error("mailer close failed", "error", err)
 	}
-	var myDatabase database
-	yamlFile, err := ioutil.ReadFile("config/database.yml")
-	if err != nil {
-		log.Printf("yamlFile.Get err   #%v ", err)
+	if tracer != nil {
+		if err := tracer.Shutdown(ctx); err != nil {
+			logging.Error("tracer shutdown failed", "error", err)
+		}
 	}
-	err = yaml.Unmarshal(yamlFile, &myDatabase)
+	if err := client.Disconnect(ctx); err != nil {
+		logging.Error("MongoDB disconnect failed", "error", err)
+	}
+}
+
+// A repository with indexes to create at startup
+type indexesEnsurer interface {
+	EnsureIndexes() error
+}
+
+// Ensures the indexes then applies the migrations, retrying until both succeed
+// Their results are given to the health service for the readiness probe
+func setupDatabase(healthService services.HealthService, databaseRepo repositories.DatabaseRepository, repos []indexesEnsurer) {
+	retryDelay := time.Second * time.Duration(config.Current.DatabaseSetupRetryInSeconds)
+	for {
+		var err error
+		for _, repo := range repos {
+			if err = repo.EnsureIndexes(); err != nil {
+				break
+			}
+		}
+		healthService.SetStepResult("indexes", err)
+		if err == nil {
+			var applied []string
+			applied, err = databaseRepo.ApplyMigrations()
+			for _, id := range applied {
+				logging.Info("migration applied", "migration", id)
+			}
+			healthService.SetStepResult("migrations", err)
+		}
+		if err == nil {
+			return
+		}
+		logging.Warn("database setup failed, retrying", "error", err, "retryInSeconds", retryDelay.Seconds())
+		time.Sleep(retryDelay)
+	}
+}
+
+// Returns the JWT key set, loaded from the key files set in the config
+func newKeySet() *signing.KeySet {
+	if config.Current.JWTSigningKeyFile == "" {
+		return signing.NewHMACKeySet([]byte(config.Current.JWTSecret))
+	}
+	keySet, err := signing.LoadKeySet(config.Current.JWTSigningKeyFile, config.Current.JWTVerificationKeyFiles)
 	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+		logging.Fatal("JWT keys not loaded", "error", err)
 	}
+	return keySet
+}
 
+// Sets the tracer of the exporter set in the config, nil if spans are not exported
+func newTracer() *tracing.Tracer {
+	if config.Current.TracingExporter != "otlp" {
+		return nil
+	}
+	tracer := tracing.NewTracer(tracing.NewOTLPExporter(config.Current.TracingOTLPEndpoint, config.Current.TracingServiceName), config.Current.TracingQueueSize)
+	tracing.SetTracer(tracer)
+	return tracer
+}
+
+// Returns the mailer of the backend set in the config
+func newMailer() mailer.Mailer {
+	switch config.Current.MailerBackend {
+	case "smtp":
+		return mailer.NewSMTPMailer(config.Current.SMTPHost, config.Current.SMTPPort, config.Current.SMTPUsername, config.Current.SMTPPassword, config.Current.MailerFrom)
+	case "file":
+		return mailer.NewFileMailer(config.Current.MailerDirectory, config.Current.MailerFrom)
+	default:
+		return mailer.NewLogMailer()
+	}
+}
+
+// Connects to the database set in the config
+func mongoDBConnect() *mongo.Database {
 	// Set client options
-	clientOptions := options.Client().ApplyURI(myDatabase.URI)
+	clientOptions := options.Client().ApplyURI(config.Current.DatabaseURI).SetMonitor(metrics.NewMongoMonitor())
 
 	// Connect to MongoDB
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("MongoDB client not created", "error", err)
 	}
 
-	// Check the connection
-	err = client.Ping(context.TODO(), nil)
+	// Check the connection, the server still starts without MongoDB but is not ready until it answers
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(config.Current.HealthPingTimeoutInSeconds))
+	defer cancel()
+	err = client.Ping(ctx, nil)
 	if err != nil {
-		log.Fatal(err)
+		logging.Warn("MongoDB does not answer yet", "error", err)
+		return client.Database(config.Current.DatabaseName)
 	}
 
-	fmt.Println("Connected to MongoDB! Database", myDatabase.Name)
-	return client.Database(myDatabase.Name)
+	logging.Info("connected to MongoDB", "database", config.Current.DatabaseName)
+	return client.Database(config.Current.DatabaseName)
 }
@@ -0,0 +1,21 @@
+package metrics
+
+// Metrics of the application, all registered in the default registry
+
+var HTTPRequestsTotal = DefaultRegistry.NewCounterVec("goapi_http_requests_total",
+	"Number of HTTP requests by method, route and status code", "method", "route", "status")
+var HTTPRequestDuration = DefaultRegistry.NewHistogramVec("goapi_http_request_duration_seconds",
+	"Duration of the HTTP requests by method and route", DurationBuckets, "method", "route")
+
+var LoginsTotal = DefaultRegistry.NewCounterVec("goapi_auth_logins_total",
+	"Number of login attempts by result (success, mfa_required, failure) and error code of the failures", "result", "reason")
+var TokenRefreshesTotal = DefaultRegistry.NewCounterVec("goapi_auth_token_refreshes_total",
+	"Number of refresh token uses by result (success, failure) and error code of the failures", "result", "reason")
+
+var MongoOperationDuration = DefaultRegistry.NewHistogramVec("goapi_mongo_operation_duration_seconds",
+	"Duration of the MongoDB commands by collection, command and status (ok, error)", DurationBuckets, "collection", "operation", "status")
+
+// Result labels of the auth counters
+const ResultSuccess = "success"
+const ResultMFARequired = "mfa_required"
+const ResultFailure = "failure"
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/event"
+	"sync"
+	"time"
+)
+
+// NewMongoMonitor returns a MongoDB command monitor which times every command sent by the repositories
+// It is set on the client options, so that no repository method has to time itself
+func NewMongoMonitor() *event.CommandMonitor {
+	var started sync.Map // collection of the started commands, by request ID
+	finished := func(requestID int64, operation string, duration int64, status string) {
+		collection, _ := started.Load(requestID)
+		started.Delete(requestID)
+		name, _ := collection.(string)
+		MongoOperationDuration.Observe(time.Duration(duration).Seconds(), name, operation, status)
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// The value of the first element is the collection, for the commands working on one
+			collection := ""
+			if element, err := e.Command.IndexErr(0); err == nil {
+				collection, _ = element.Value().StringValueOK()
+			}
+			started.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finished(e.RequestID, e.CommandName, e.DurationNanos, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finished(e.RequestID, e.CommandName, e.DurationNanos, "error")
+		},
+	}
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metrics and writes them in the Prometheus text exposition format
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// A metric family, written with all its label values
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry holds the metrics of the application, exposed on /metrics
+var DefaultRegistry = NewRegistry()
+
+// NewCounterVec registers a counter, one value per combination of the label values
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: family{name: name, help: help, labels: labels}, values: map[string]*counterValue{}}
+	r.register(c)
+	return c
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, one histogram per combination of the label values
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: family{name: name, help: help, labels: labels}, buckets: buckets, values: map[string]*histogramValue{}}
+	r.register(h)
+	return h
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// Name, help and label names of a metric
+type family struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (f family) writeHeader(w *bufio.Writer, metricType string) {
+	w.WriteString("# HELP " + f.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+	w.WriteString("# TYPE " + f.name + " " + metricType + "\n")
+}
+
+// Returns the key of label values, used to store their value
+// Missing label values are empty, extra ones are ignored
+func (f family) key(labelValues []string) string {
+	values := make([]string, len(f.labels))
+	copy(values, labelValues)
+	return strings.Join(values, "\xff")
+}
+
+// Returns the label set of a key, as written after the metric name, with extra labels such as le
+func (f family) labelSet(key string, extra ...string) string {
+	var pairs []string
+	if len(f.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, f.labels[i]+`="`+escapeLabelValue(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabelValue(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter which only goes up, such as the number of requests
+type CounterVec struct {
+	family
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	value float64
+}
+
+// Inc adds 1 to the counter of the label values, given in the order of the label names
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a positive value to the counter of the label values
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	counter, ok := c.values[key]
+	if !ok {
+		counter = &counterValue{}
+		c.values[key] = counter
+	}
+	counter.value += value
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	for _, key := range sortedKeys(keys) {
+		w.WriteString(c.name + c.labelSet(key) + " " + formatFloat(c.values[key].value) + "\n")
+	}
+}
+
+// HistogramVec counts observations, such as durations, in buckets
+type HistogramVec struct {
+	family
+	buckets []float64 // upper bounds, sorted
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64 // not cumulative, one per bucket
+	count  uint64
+	sum    float64
+}
+
+// DurationBuckets are the default buckets of durations in seconds, from 5ms to 10s
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Observe adds an observation to the histogram of the label values, given in the order of the label names
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	histogram, ok := h.values[key]
+	if !ok {
+		histogram = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = histogram
+	}
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			histogram.counts[i]++
+			break
+		}
+	}
+	histogram.count++
+	histogram.sum += value
+	h.mu.Unlock()
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]string, 0, len(h.values))
+	for key := range h.values {
+		keys = append(keys, key)
+	}
+	for _, key := range sortedKeys(keys) {
+		histogram := h.values[key]
+		cumulative := uint64(0)
+		for i, upperBound := range h.buckets {
+			cumulative += histogram.counts[i]
+			w.WriteString(h.name + "_bucket" + h.labelSet(key, "le", formatFloat(upperBound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		w.WriteString(h.name + "_bucket" + h.labelSet(key, "le", "+Inf") + " " + strconv.FormatUint(histogram.count, 10) + "\n")
+		w.WriteString(h.name + "_sum" + h.labelSet(key) + " " + formatFloat(histogram.sum) + "\n")
+		w.WriteString(h.name + "_count" + h.labelSet(key) + " " + strconv.FormatUint(histogram.count, 10) + "\n")
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterText(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("http_requests_total", "Number of requests.\nBy route.", "method", "route")
+	requests.Inc("GET", "/houses")
+	requests.Add(2, "GET", "/houses")
+	requests.Add(-1, "GET", "/houses") // a counter never goes down
+	requests.Inc("POST", `/say "hi"\now`+"\n")
+	requests.Inc("DELETE") // missing label values are empty
+	registry.NewCounterVec("unused_total", "Never incremented.")
+
+	want := `# HELP http_requests_total Number of requests.\nBy route.
+# TYPE http_requests_total counter
+http_requests_total{method="DELETE",route=""} 1
+http_requests_total{method="GET",route="/houses"} 3
+http_requests_total{method="POST",route="/say \"hi\"\\now\n"} 1
+# HELP unused_total Never incremented.
+# TYPE unused_total counter
+`
+	var text strings.Builder
+	if err := registry.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", text.String(), want)
+	}
+}
+
+func TestHistogramText(t *testing.T) {
+	registry := NewRegistry()
+	durations := registry.NewHistogramVec("db_duration_seconds", "Duration of the operations.", []float64{0.1, 0.5, 1}, "operation")
+	for _, value := range []float64{0.05, 0.1, 0.3, 2} {
+		durations.Observe(value, "find")
+	}
+	noLabels := registry.NewHistogramVec("job_duration_seconds", "Duration of the jobs.", []float64{1})
+	noLabels.Observe(0.25)
+
+	want := `# HELP db_duration_seconds Duration of the operations.
+# TYPE db_duration_seconds histogram
+db_duration_seconds_bucket{operation="find",le="0.1"} 2
+db_duration_seconds_bucket{operation="find",le="0.5"} 3
+db_duration_seconds_bucket{operation="find",le="1"} 3
+db_duration_seconds_bucket{operation="find",le="+Inf"} 4
+db_duration_seconds_sum{operation="find"} 2.45
+db_duration_seconds_count{operation="find"} 4
+# HELP job_duration_seconds Duration of the jobs.
+# TYPE job_duration_seconds histogram
+job_duration_seconds_bucket{le="1"} 1
+job_duration_seconds_bucket{le="+Inf"} 1
+job_duration_seconds_sum 0.25
+job_duration_seconds_count 1
+`
+	var text strings.Builder
+	if err := registry.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if text.String() != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", text.String(), want)
+	}
+}
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/models"
+	"goapi/services"
+	"strings"
+)
+
+const principalKey = "principal"
+
+// Authenticate returns a middleware protecting the routes declared behind it
+// The request must have a valid access token in its "Authorization: Bearer {token}" header, see AuthService.Authenticate
+// The principal of the request is then available with PrincipalFromCtx and CallerFromCtx
+func Authenticate(authService services.AuthService) func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		signedToken, err := bearerToken(ctx)
+		if err != nil {
+			Fail(ctx, err)
+			return
+		}
+		principal, err := authService.Authenticate(RequestContext(ctx), signedToken)
+		if err != nil {
+			Fail(ctx, err)
+			return
+		}
+		ctx.Locals(principalKey, principal)
+		ctx.Next()
+	}
+}
+
+// PrincipalFromCtx returns the principal set by the auth middleware, false on the unauthenticated routes
+func PrincipalFromCtx(ctx *fiber.Ctx) (models.Principal, bool) {
+	principal, ok := ctx.Locals(principalKey).(models.Principal)
+	return principal, ok
+}
+
+// Returns the JWT of the authorization header
+func bearerToken(ctx *fiber.Ctx) (string, error) {
+	authHeader := ctx.Get(fiber.HeaderAuthorization)
+	if authHeader == "" {
+		return "", errors.JWTMissing
+	}
+	authHeaderParts := strings.Split(authHeader, " ")
+	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" || authHeaderParts[1] == "" {
+		return "", errors.JWTNotBearer
+	}
+	return authHeaderParts[1], nil
+}
@@ -0,0 +1,13 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/models"
+)
+
+// Returns the caller identity of the principal stored in the context by the auth middleware
+// An empty caller is returned on the unauthenticated routes
+func CallerFromCtx(ctx *fiber.Ctx) models.Caller {
+	principal, _ := PrincipalFromCtx(ctx)
+	return principal.Caller
+}
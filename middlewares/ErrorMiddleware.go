@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/logging"
+	"strconv"
+	"time"
+)
+
+const errorKey = "error"
+
+// Fail stores the error of a request, to send with the error envelope once the handler returns
+// The handler must return without sending anything after calling it
+func Fail(ctx *fiber.Ctx, err error) {
+	ctx.Locals(errorKey, err)
+}
+
+// HandleErrors returns a middleware sending the errors given to Fail, in the error envelope:
+// {"success": false, "error": "...", "errorCode": "...", "details": [...], "requestID": "..."}
+// Errors which are not *errors.APIError are sent as internal server errors, without their message
+// The unmatched routes and the panics of the handlers are sent in the same envelope
+// It must be used after the logging middleware so that the request ID is known
+func HandleErrors() func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		defer func() {
+			if r := recover(); r != nil {
+				sendError(ctx, errors.InternalServerError.Wrap(fmt.Errorf("panic: %v", r)))
+			}
+		}()
+
+		ctx.Next()
+
+		if err, ok := ctx.Locals(errorKey).(error); ok && err != nil {
+			sendError(ctx, err)
+		} else if ctx.Route() == nil { // no route matched, fiber sent a plain text 404
+			sendError(ctx, errors.ResourceNotFound)
+		}
+	}
+}
+
+func sendError(ctx *fiber.Ctx, err error) {
+	apiErr := errors.From(err)
+	if apiErr.Status >= fiber.StatusInternalServerError {
+		logging.FromContext(RequestContext(ctx)).Error("request failed", "errorCode", apiErr.Code, "error", err)
+	}
+	body := fiber.Map{
+		"success":   false,
+		"error":     apiErr.Description,
+		"errorCode": apiErr.Code,
+		"requestID": RequestID(ctx),
+	}
+	if len(apiErr.Details) > 0 {
+		body["details"] = apiErr.Details
+	}
+	if apiErr.RetryAfter > 0 { // in seconds, rounded up so that the client does not come back too early
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int((apiErr.RetryAfter+time.Second-1)/time.Second)))
+	}
+	_ = ctx.Status(apiErr.Status).JSON(body)
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"no delay", errors.LoginThrottled, ""},
+		{"whole seconds", errors.LoginThrottled.WithRetryAfter(2 * time.Second), "2"},
+		{"rounded up", errors.LoginTemporarilyLocked.WithRetryAfter(1500 * time.Millisecond), "2"},
+		{"less than a second", errors.LoginThrottled.WithRetryAfter(time.Millisecond), "1"},
+	}
+	for _, test := range tests {
+		app := fiber.New()
+		app.Use(HandleErrors())
+		failure := test.err
+		app.Post("/auth/login", func(ctx *fiber.Ctx) { Fail(ctx, failure) })
+		resp, err := app.Test(httptest.NewRequest("POST", "/auth/login", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusTooManyRequests || resp.Header.Get(fiber.HeaderRetryAfter) != test.want {
+			t.Errorf("%s: status %d, Retry-After %q, want %d, %q", test.name, resp.StatusCode, resp.Header.Get(fiber.HeaderRetryAfter), fiber.StatusTooManyRequests, test.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gofiber/fiber"
+	"goapi/logging"
+	"goapi/tracing"
+	"time"
+)
+
+// RequestIDHeader identifies a request in the logs, it is taken from the client or generated
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "requestID"
+
+// LogRequests returns a middleware writing a log line for each request, with its route, status, latency and user ID
+// The request ID sent by the client is kept if it is valid, otherwise a new one is generated,
+// it is returned in the X-Request-ID header and in the error responses so that support can find the request
+// The request context carries a logger adding the request ID to every line, see logging.FromContext
+// It must be used after the tracing middleware so that the span of the request is kept in the request context
+func LogRequests() func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		start := time.Now()
+		requestID := ctx.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Locals(requestIDKey, requestID)
+		ctx.Set(RequestIDHeader, requestID)
+		logger := logging.Default().With("requestID", requestID)
+		requestCtx := logging.ContextWithLogger(RequestContext(ctx), logger)
+		ctx.Locals(requestContextKey, requestCtx)
+		if span := tracing.SpanFromContext(requestCtx); span != nil {
+			span.SetAttribute("http.request_id", requestID)
+		}
+
+		ctx.Next()
+
+		statusCode := ctx.Fasthttp.Response.StatusCode()
+		level := logging.LevelInfo
+		if statusCode >= fiber.StatusInternalServerError {
+			level = logging.LevelError
+		}
+		keyvals := []interface{}{
+			"method", ctx.Method(),
+			"route", routeOf(ctx),
+			"path", ctx.Path(),
+			"status", statusCode,
+			"latencyMs", float64(time.Since(start).Microseconds()) / 1000,
+			"ip", ctx.IP(),
+		}
+		if userID := CallerFromCtx(ctx).UserID; userID != "" {
+			keyvals = append(keyvals, "userID", userID)
+		}
+		logger.Log(level, "request", keyvals...)
+	}
+}
+
+// RequestID returns the ID of the request, empty if the logging middleware is not used
+func RequestID(ctx *fiber.Ctx) string {
+	requestID, _ := ctx.Locals(requestIDKey).(string)
+	return requestID
+}
+
+// Accepts the IDs of up to 128 letters, digits, dashes, underscores, dots and colons,
+// so that a client can not write anything in the logs
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
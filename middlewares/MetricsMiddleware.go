@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/metrics"
+	"strconv"
+	"time"
+)
+
+// RecordMetrics counts and times the requests, by route and status code
+// The route is the path of the matched route (e.g. /v0/users/:id), so that ids do not create new series
+func RecordMetrics() func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		start := time.Now()
+		ctx.Next()
+
+		route := routeOf(ctx)
+		method := ctx.Method()
+		metrics.HTTPRequestsTotal.Inc(method, route, strconv.Itoa(ctx.Fasthttp.Response.StatusCode()))
+		metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+)
+
+// RequireRoles returns a middleware protecting the routes declared behind it
+// The caller must have at least one of the given roles, otherwise a forbidden error is sent
+// It must be used after the JWT middleware
+func RequireRoles(roles ...string) func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		caller := CallerFromCtx(ctx)
+		for _, role := range roles {
+			if caller.HasRole(role) {
+				ctx.Next()
+				return
+			}
+		}
+		Fail(ctx, errors.Forbidden)
+	}
+}
@@ -0,0 +1,55 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber"
+	"goapi/tracing"
+)
+
+const requestContextKey = "requestContext"
+
+// Trace returns a middleware starting a server span for each request
+// The span continues the trace of the W3C traceparent header if the client sent one,
+// its context is returned in the traceresponse header so that clients can find the trace
+// Handlers get the context carrying the span with RequestContext, and give it to the services
+func Trace() func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		parent, _ := tracing.Extract(ctx.Get(tracing.TraceparentHeader))
+		requestCtx, span := tracing.StartWithParent(context.Background(), parent, ctx.Method()+" "+ctx.Path(), tracing.SpanKindServer)
+		ctx.Locals(requestContextKey, requestCtx)
+		ctx.Set("traceresponse", tracing.Format(span.Context()))
+
+		ctx.Next()
+
+		statusCode := ctx.Fasthttp.Response.StatusCode()
+		span.SetName(ctx.Method() + " " + routeOf(ctx))
+		span.SetAttribute("http.method", ctx.Method())
+		span.SetAttribute("http.route", routeOf(ctx))
+		span.SetAttribute("http.target", ctx.OriginalURL())
+		span.SetAttribute("http.status_code", statusCode)
+		if statusCode >= fiber.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP status %d", statusCode))
+		}
+		span.End()
+	}
+}
+
+// RequestContext returns the context of the request, carrying its span
+// A background context is returned if the tracing middleware is not used
+func RequestContext(ctx *fiber.Ctx) context.Context {
+	if requestCtx, ok := ctx.Locals(requestContextKey).(context.Context); ok {
+		return requestCtx
+	}
+	return context.Background()
+}
+
+// Returns the path of the route which answered the request, e.g. /v0/users/:id
+// Requests answered by a middleware (e.g. 401 of the JWT middleware) get the middleware path,
+// and the requests matching no route get "unmatched"
+func routeOf(ctx *fiber.Ctx) string {
+	if route := ctx.Route(); route != nil {
+		return route.Path
+	}
+	return "unmatched"
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gofiber/fiber"
+	"goapi/tracing"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter, 16)
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+
+	app := fiber.New()
+	app.Use(Trace())
+	app.Get("/v0/houses/:id", func(ctx *fiber.Ctx) {
+		ctx.Status(fiber.StatusServiceUnavailable)
+	})
+	req := httptest.NewRequest("GET", "/v0/houses/42?fields=name", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /v0/houses/:id" || span.Kind != tracing.SpanKindServer || !span.Error {
+		t.Errorf("span = %+v, want a server span in error named GET /v0/houses/:id", span)
+	}
+	if span.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("span = %+v, want the trace and parent of the traceparent header", span)
+	}
+	wantAttributes := map[string]interface{}{
+		"http.method":      "GET",
+		"http.route":       "/v0/houses/:id",
+		"http.target":      "/v0/houses/42?fields=name",
+		"http.status_code": fiber.StatusServiceUnavailable,
+	}
+	for key, want := range wantAttributes {
+		if got := span.Attributes[key]; got != want {
+			t.Errorf("attribute %s = %v, want %v", key, got, want)
+		}
+	}
+	if got := resp.Header.Get("traceresponse"); got != tracing.Format(span.SpanContext) {
+		t.Errorf("traceresponse = %s, want %s", got, tracing.Format(span.SpanContext))
+	}
+}
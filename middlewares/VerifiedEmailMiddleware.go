@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber"
+	"goapi/errors"
+	"goapi/services"
+)
+
+// RequireVerifiedEmail returns a middleware protecting the routes declared behind it
+// The caller must have verified its email address, otherwise a forbidden error is sent
+// It must be used after the JWT middleware
+func RequireVerifiedEmail(authService services.AuthService) func(*fiber.Ctx) {
+	return func(ctx *fiber.Ctx) {
+		verified, err := authService.IsEmailVerified(RequestContext(ctx), CallerFromCtx(ctx).UserID)
+		if err != nil {
+			Fail(ctx, err)
+			return
+		}
+		if !verified {
+			Fail(ctx, errors.EmailNotVerified)
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+// Caller is the identity of the user performing a request
+// It is built from the JWT by the auth middleware, see Principal, and passed to the services
+// so they can decide whether the request is allowed or not
+type Caller struct {
+	UserID string
+	Roles  []string
+}
+
+// Tells if the caller has the given role
+func (c Caller) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if the caller is an admin
+func (c Caller) IsAdmin() bool {
+	return c.HasRole(RoleAdmin)
+}
+
+// Tells if the caller is allowed to access a resource owned by ownerID
+// Admins are allowed to access every resource
+func (c Caller) CanAccess(ownerID string) bool {
+	return c.IsAdmin() || (c.UserID != "" && c.UserID == ownerID)
+}
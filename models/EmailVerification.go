@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// An email verification token is never stored in clear, only its hash is
+// It is bound to the email address it was sent to, so that it can not verify another one
+type EmailVerification struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"userID" bson:"userID"`
+	Email     string    `json:"email" bson:"email"`
+	TokenHash string    `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	Used      bool      `json:"used" bson:"used"`
+}
@@ -0,0 +1,17 @@
+package models
+
+const HealthStatusOK = "ok"
+const HealthStatusPending = "pending" // startup step not done yet
+const HealthStatusFail = "fail"
+
+// Health of the server, with the detail of each dependency
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// Health of a dependency or of a startup step
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
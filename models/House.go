@@ -1,9 +1,14 @@
 package models
 
+// The members are only changed by the member routes, and the owner of an existing house by an ownership transfer,
+// they are ignored when sent with a house
 type House struct {
 	ID     string  `json:"id" bson:"_id,omitempty"`
 	UserID string  `json:"userID" bson:"userID,omitempty"`
-	Name   string  `json:"name" bson:"name,omitempty"`
-	City   string  `json:"city" bson:"city,omitempty"`
-	Rooms  *[]Room `json:"rooms" bson:"rooms,omitempty"`
+	Name   string  `json:"name" bson:"name,omitempty" validate:"required,max=100"`
+	City   string  `json:"city" bson:"city,omitempty" validate:"max=100"`
+	Rooms  *[]Room `json:"rooms" bson:"rooms,omitempty" validate:"max=100"`
+
+	Members         []HouseMember      `json:"members" bson:"members,omitempty"`
+	PendingTransfer *OwnershipTransfer `json:"pendingTransfer,omitempty" bson:"pendingTransfer,omitempty"`
 }
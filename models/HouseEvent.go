@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// The types of the events of a house history
+const HouseEventTransferRequested = "ownershipTransferRequested"
+const HouseEventTransferCancelled = "ownershipTransferCancelled"
+const HouseEventTransferred = "ownershipTransferred"
+
+// HouseEvent is an entry of the history of a house, e.g. an ownership transfer
+// UserID is the user who did it, the from and to users are the previous and new owners of a transfer
+type HouseEvent struct {
+	ID         string    `json:"id" bson:"_id,omitempty"`
+	HouseID    string    `json:"houseID" bson:"houseID"`
+	Type       string    `json:"type" bson:"type"`
+	UserID     string    `json:"userID" bson:"userID"`
+	FromUserID string    `json:"fromUserID,omitempty" bson:"fromUserID,omitempty"`
+	ToUserID   string    `json:"toUserID,omitempty" bson:"toUserID,omitempty"`
+	At         time.Time `json:"at" bson:"at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// Roles of the users of a house, from the most to the least allowed
+// The owner is the user of House.UserID, the other users are its members
+const HouseRoleOwner = "owner"
+const HouseRoleEditor = "editor" // updates the house and its rooms
+const HouseRoleViewer = "viewer" // reads the house and its rooms
+
+var houseRoleRanks = map[string]int{HouseRoleViewer: 1, HouseRoleEditor: 2, HouseRoleOwner: 3}
+
+// HouseMember is an user sharing a house with its owner
+type HouseMember struct {
+	UserID string `json:"userID" bson:"userID" validate:"required"`
+	Role   string `json:"role" bson:"role" validate:"required,oneof=editor viewer"`
+}
+
+// Returns the role of an user in the house, empty if he is neither its owner nor a member
+func (h House) RoleOf(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	if userID == h.UserID {
+		return HouseRoleOwner
+	}
+	for _, member := range h.Members {
+		if member.UserID == userID {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// Tells if a role is allowed to do what the minimum role is allowed to, e.g. an owner is allowed to edit
+func HouseRoleAllows(role string, minimum string) bool {
+	return houseRoleRanks[role] > 0 && houseRoleRanks[role] >= houseRoleRanks[minimum]
+}
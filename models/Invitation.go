@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// The statuses of an invitation, only a pending one can be accepted, declined or revoked
+const InvitationPending = "pending"
+const InvitationAccepted = "accepted"
+const InvitationDeclined = "declined"
+const InvitationRevoked = "revoked"
+
+// An invitation to become a member of a house, sent by email to someone who may not have an account yet
+// Its token is never stored in clear, only its hash is, it can only be accepted by the user of the email address
+type Invitation struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	HouseID   string    `json:"houseID" bson:"houseID"`
+	Email     string    `json:"email" bson:"email" validate:"required,email,max=254"`
+	Role      string    `json:"role" bson:"role" validate:"required,oneof=editor viewer"`
+	InvitedBy string    `json:"invitedBy" bson:"invitedBy"` // user id
+	TokenHash string    `json:"-" bson:"tokenHash"`
+	Status    string    `json:"status" bson:"status"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,37 @@
+package models
+
+// The operators of a Filter
+const (
+	FilterEqual    = "eq"
+	FilterContains = "contains" // case insensitive, strings only
+	FilterMin      = "min"      // greater than or equal
+	FilterMax      = "max"      // less than or equal
+)
+
+// ListQuery is what a client asks to a list route: which elements, in which order, and which page of them
+type ListQuery struct {
+	Filters []Filter
+	Sort    []SortField // the id is always the last sort field, so that the order is total
+	Limit   int
+	Cursor  string // opaque, given by a previous page, empty for the first page
+}
+
+// Filter keeps the elements of which field compares to value with the operator, e.g. city eq Paris
+type Filter struct {
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// Page tells where a page of a list is: the number of elements matching the filters, and the cursors of the
+// next and previous pages, empty when there is no such page
+type Page struct {
+	Total int64
+	Next  string
+	Prev  string
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Failed login attempts of an account or an IP address, identified by the key
+// Keys are "account:" followed by the email address or "ip:" followed by the IP address
+type LoginAttempt struct {
+	Key           string    `json:"key" bson:"_id"`
+	Failures      int       `json:"failures" bson:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" bson:"lastFailureAt"` // or of the last reserved attempt
+	LockedUntil   time.Time `json:"lockedUntil" bson:"lockedUntil"`
+	ExpiresAt     time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// Migration applied on the database, identified by its ID
+type Migration struct {
+	ID        string    `json:"id" bson:"_id"`
+	AppliedAt time.Time `json:"appliedAt" bson:"appliedAt"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// OwnershipTransfer is the transfer of a house to a new owner, pending until he accepts it
+// The previous owner is not a member of the house anymore once it is accepted
+type OwnershipTransfer struct {
+	FromUserID string    `json:"fromUserID" bson:"fromUserID"`
+	ToUserID   string    `json:"toUserID" bson:"toUserID" validate:"required"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt" bson:"expiresAt"`
+}
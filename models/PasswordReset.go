@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// A password reset token is never stored in clear, only its hash is
+// It can be used only once, before it expires
+type PasswordReset struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"userID" bson:"userID"`
+	TokenHash string    `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	Used      bool      `json:"used" bson:"used"`
+}
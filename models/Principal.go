@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Principal is the authenticated user of a request, with the JWT it used
+// It is put in the request locals by the auth middleware, once the JWT is verified
+type Principal struct {
+	Caller
+	TokenID   string // jti of the JWT, empty for the tokens generated before it existed
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// A refresh token is never stored in clear, only its hash is
+// Tokens generated from the same login share the same family, so that they can all be revoked at once
+type RefreshToken struct {
+	ID        string     `json:"id" bson:"_id,omitempty"`
+	UserID    string     `json:"userID" bson:"userID"`
+	FamilyID  string     `json:"familyID" bson:"familyID"`
+	TokenHash string     `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	Used      bool       `json:"used" bson:"used"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+	Revoked   bool       `json:"revoked" bson:"revoked"`
+}
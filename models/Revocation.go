@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// A revocation invalidates JWTs before their expiration
+// With a JTI, it revokes this single token
+// Without a JTI, it revokes every token of the user issued before RevokedBefore, or during its millisecond
+// It is kept until ExpiresAt, after which the revoked tokens are expired anyway
+type Revocation struct {
+	ID            string    `json:"id" bson:"_id,omitempty"`
+	JTI           string    `json:"jti,omitempty" bson:"jti,omitempty"`
+	UserID        string    `json:"userID" bson:"userID"`
+	RevokedBefore time.Time `json:"revokedBefore" bson:"revokedBefore"`
+	ExpiresAt     time.Time `json:"expiresAt" bson:"expiresAt"`
+}
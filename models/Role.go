@@ -0,0 +1,9 @@
+package models
+
+// Built-in roles
+// Any other string can be used as a custom role, it will be stored and embedded in the JWT the same way
+const RoleUser = "user"
+const RoleAdmin = "admin"
+
+// Roles given to every newly registered user
+var DefaultRoles = []string{RoleUser}
@@ -1,7 +1,9 @@
 package models
 
+// The id of a room is set by the server, it is unique within its house
 type Room struct {
-	Name           string `json:"name" bson:"name"`
-	Description    string `json:"description" bson:"description"`
-	Surface        int    `json:"surface" bson:"surface"`
+	ID          string `json:"id" bson:"id"`
+	Name        string `json:"name" bson:"name" validate:"required,max=50"`
+	Description string `json:"description" bson:"description" validate:"max=500"`
+	Surface     int    `json:"surface" bson:"surface" validate:"required,min=1,max=100000"`
 }
@@ -1,14 +1,23 @@
 package models
 
 // Password and salt fields will never be sent
+// Password is only sent back by the client when creating or updating an user
+// The fields sent by the client are checked with their validate tags, see the validation package
 type User struct {
-	ID        string `json:"id" bson:"_id,omitempty"`
-	FirstName string `json:"firstName" bson:"firstName,omitempty"`
-	LastName  string `json:"lastName" bson:"lastName,omitempty"`
-	Email     string `json:"email" bson:"email,omitempty"`
-	Salt      string `json:"-" bson:"salt,omitempty"`
-	Password  string `json:",omitempty" bson:"password,omitempty"`
-	Language  string `json:"language" bson:"language,omitempty"`
-	Verified  bool   `json:"verified" bson:"verified,omitempty"`
-	Enabled   bool   `json:"enabled" bson:"enabled,omitempty"`
+	ID        string   `json:"id" bson:"_id,omitempty"`
+	FirstName string   `json:"firstName" bson:"firstName,omitempty" validate:"required,max=50"`
+	LastName  string   `json:"lastName" bson:"lastName,omitempty" validate:"required,max=50"`
+	Email     string   `json:"email" bson:"email,omitempty" validate:"required,email,max=254"`
+	Salt      string   `json:"-" bson:"salt,omitempty"`
+	Password  string   `json:"password,omitempty" bson:"password,omitempty" validate:"required,min=8,max=128"`
+	Language  string   `json:"language" bson:"language,omitempty" validate:"required,oneof=en fr"`
+	Verified  bool     `json:"verified" bson:"verified,omitempty"`
+	Enabled   bool     `json:"enabled" bson:"enabled,omitempty"`
+	Roles     []string `json:"roles" bson:"roles,omitempty"`
+
+	// Two-factor authentication, the secret and recovery codes are never sent either
+	TOTPEnabled   bool     `json:"totpEnabled" bson:"totpEnabled,omitempty"`
+	TOTPSecret    string   `json:"-" bson:"totpSecret,omitempty"`
+	TOTPLastStep  int64    `json:"-" bson:"totpLastStep,omitempty"`
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes,omitempty"` // hashed
 }
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// DatabaseRepository handles the operations on the whole database: health and migrations
+type DatabaseRepository interface {
+	Ping(ctx context.Context) error
+	ApplyMigrations() (applied []string, err error)
+}
+
+// NewDatabaseRepository returns a new database repository,
+// Applied migrations are recorded in the migrations collection
+func NewDatabaseRepository(database *mongo.Database) DatabaseRepository {
+	return &databaseRepository{database: database, collection: database.Collection("migrations")}
+}
+
+// databaseRepository is a "DatabaseRepository"
+// which manages the mongoDB database
+type databaseRepository struct {
+	database   *mongo.Database
+	collection *mongo.Collection
+}
+
+// A migration changes the stored documents when the models change
+// Migrations must be idempotent, several instances starting together may apply the same one
+type migration struct {
+	id string
+	up func(database *mongo.Database) error
+}
+
+// Migrations in the order they are applied, new ones are appended
+var migrations = []migration{
+	{id: "0001-default-user-roles", up: setDefaultUserRoles},
+	{id: "0002-room-ids", up: setRoomIDs},
+}
+
+// Checks that the database answers
+func (r databaseRepository) Ping(ctx context.Context) error {
+	return r.database.Client().Ping(ctx, nil)
+}
+
+// Applies the migrations not applied yet, in order, and returns their IDs
+func (r databaseRepository) ApplyMigrations() (applied []string, err error) {
+	cursor, err := r.collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var done []models.Migration
+	if err = cursor.All(context.TODO(), &done); err != nil {
+		return nil, err
+	}
+	isDone := map[string]bool{}
+	for _, m := range done {
+		isDone[m.ID] = true
+	}
+
+	for _, m := range migrations {
+		if isDone[m.id] {
+			continue
+		}
+		if err = m.up(r.database); err != nil {
+			return applied, err
+		}
+		_, err = r.collection.UpdateOne(context.TODO(), bson.M{"_id": m.id},
+			bson.M{"$setOnInsert": bson.M{"appliedAt": time.Now()}}, options.Update().SetUpsert(true))
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.id)
+	}
+	return applied, nil
+}
+
+// Users stored before roles existed get the default roles
+func setDefaultUserRoles(database *mongo.Database) error {
+	_, err := database.Collection("users").UpdateMany(context.TODO(),
+		bson.M{"roles": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"roles": models.DefaultRoles}})
+	return err
+}
+
+// Rooms stored before they had an id get one, so that they can be reached by the rooms routes
+// Each house is only updated if its rooms did not change in between, e.g. by another instance
+func setRoomIDs(database *mongo.Database) error {
+	houses := database.Collection("houses")
+	cursor, err := houses.Find(context.TODO(), bson.M{"rooms": bson.M{"$elemMatch": bson.M{"id": bson.M{"$exists": false}}}})
+	if err != nil {
+		return err
+	}
+	var found []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Rooms []bson.D           `bson:"rooms"`
+	}
+	if err = cursor.All(context.TODO(), &found); err != nil {
+		return err
+	}
+	for _, house := range found {
+		rooms := make([]bson.D, len(house.Rooms))
+		for i, room := range house.Rooms {
+			rooms[i] = room
+			if _, hasID := room.Map()["id"]; !hasID {
+				rooms[i] = append(append(bson.D{}, room...), bson.E{Key: "id", Value: primitive.NewObjectID().Hex()})
+			}
+		}
+		_, err = houses.UpdateOne(context.TODO(), bson.M{"_id": house.ID, "rooms": house.Rooms}, bson.M{"$set": bson.M{"rooms": rooms}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
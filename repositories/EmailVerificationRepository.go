@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// EmailVerificationRepository handles the basic operations of an email verification entity/model.
+type EmailVerificationRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, verification models.EmailVerification) (insertedID string, err error)
+
+	SelectLastOfUser(ctx context.Context, userID string) (verification models.EmailVerification, found bool, err error)
+
+	Consume(ctx context.Context, tokenHash string) (verification models.EmailVerification, found bool, err error)
+
+	InvalidateAllOfUser(ctx context.Context, userID string) error
+}
+
+// NewEmailVerificationRepository returns a new email verification repository,
+// Requires the collection corresponding to email verifications from the mongo database
+func NewEmailVerificationRepository(collection *mongo.Collection) EmailVerificationRepository {
+	return &emailVerificationRepository{collection: collection}
+}
+
+// emailVerificationRepository is a "EmailVerificationRepository"
+// which manages the email verifications using the mongoDB collection
+type emailVerificationRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Hashes are unique and expired verifications are removed by mongo itself
+func (r emailVerificationRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"tokenHash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter}},
+	})
+	return err
+}
+
+// Insert an email verification in database
+func (r emailVerificationRepository) Insert(ctx context.Context, verification models.EmailVerification) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "EmailVerificationRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, verification)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// Select the last email verification sent to an user
+// Used to throttle the verification emails
+func (r emailVerificationRepository) SelectLastOfUser(ctx context.Context, userID string) (verification models.EmailVerification, found bool, err error) {
+	ctx, op := startOperation(ctx, "EmailVerificationRepository.SelectLastOfUser", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"userID": userID}
+	option := options.FindOne().SetSort(bson.M{"createdAt": -1})
+	err = r.collection.FindOne(ctx, filter, option).Decode(&verification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.EmailVerification{}, false, nil
+		}
+		return models.EmailVerification{}, false, err
+	}
+	return verification, true, nil
+}
+
+// Marks an unused and unexpired email verification as used and returns it
+// This is atomic, a token can only be consumed once
+func (r emailVerificationRepository) Consume(ctx context.Context, tokenHash string) (verification models.EmailVerification, found bool, err error) {
+	ctx, op := startOperation(ctx, "EmailVerificationRepository.Consume", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"tokenHash": tokenHash, "used": false, "expiresAt": bson.M{"$gt": time.Now()}}
+	update := bson.M{"$set": bson.M{"used": true}}
+	err = r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&verification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.EmailVerification{}, false, nil
+		}
+		return models.EmailVerification{}, false, err
+	}
+	return verification, true, nil
+}
+
+// Marks every email verification of an user as used
+// Only the last sent verification link stays valid
+func (r emailVerificationRepository) InvalidateAllOfUser(ctx context.Context, userID string) (err error) {
+	ctx, op := startOperation(ctx, "EmailVerificationRepository.InvalidateAllOfUser", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"userID": userID, "used": false}
+	update := bson.M{"$set": bson.M{"used": true}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
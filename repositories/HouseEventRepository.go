@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"goapi/models"
+)
+
+// HouseEventRepository handles the basic operations of a house event entity/model.
+type HouseEventRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, event models.HouseEvent) (insertedID string, err error)
+
+	SelectPage(ctx context.Context, query models.ListQuery) (events []models.HouseEvent, page models.Page, err error)
+
+	DeleteByHouseID(ctx context.Context, houseID string) error
+}
+
+// NewHouseEventRepository returns a new house event repository,
+// Requires the collection corresponding to house events from the mongo database
+func NewHouseEventRepository(collection *mongo.Collection) HouseEventRepository {
+	return &houseEventRepository{collection: collection}
+}
+
+// houseEventRepository is a "HouseEventRepository"
+// which manages the house events using the mongoDB collection
+type houseEventRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// The history of a house is listed by its id
+func (r houseEventRepository) EnsureIndexes() error {
+	_, err := r.collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "houseID", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	return err
+}
+
+// Insert a house event in database
+func (r houseEventRepository) Insert(ctx context.Context, event models.HouseEvent) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "HouseEventRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// The fields which can filter or sort the history of a house
+var houseEventListFields = map[string]listField{
+	"houseID": {expr: "$houseID", zero: ""},
+	"type":    {expr: "$type", zero: ""},
+	"at":      {expr: bson.M{"$toLong": "$at"}, zero: 0}, // in milliseconds, so that the cursors can hold it
+}
+
+// Select a page of house events from the database, see models.ListQuery
+// The history of a house is the events filtered by houseID
+func (r houseEventRepository) SelectPage(ctx context.Context, query models.ListQuery) (events []models.HouseEvent, page models.Page, err error) {
+	ctx, op := startOperation(ctx, "HouseEventRepository.SelectPage", r.collection)
+	defer op.end(&err)
+	docs, page, err := selectPage(ctx, r.collection, houseEventListFields, query)
+	if err != nil {
+		return nil, models.Page{}, err
+	}
+	events = make([]models.HouseEvent, len(docs))
+	for key, doc := range docs {
+		err = bson.Unmarshal(doc, &events[key])
+		if err != nil {
+			return nil, models.Page{}, err
+		}
+	}
+	return events, page, nil
+}
+
+// Delete the history of a house, once it is deleted
+func (r houseEventRepository) DeleteByHouseID(ctx context.Context, houseID string) (err error) {
+	ctx, op := startOperation(ctx, "HouseEventRepository.DeleteByHouseID", r.collection)
+	defer op.end(&err)
+	_, err = r.collection.DeleteMany(ctx, bson.M{"houseID": houseID})
+	return err
+}
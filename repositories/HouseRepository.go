@@ -5,21 +5,34 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"goapi/models"
+	"strconv"
+	"time"
 )
 
 // HouseRepository handles the basic operations of a house entity/model.
 type HouseRepository interface {
-	Insert(house models.House) (string, error)
+	EnsureIndexes() error
 
-	SelectByID(id string) (house models.House, found bool)
-	SelectByUserID(userId string) (houses []models.House, found bool)
-	SelectMany(limit int) ([]models.House, error)
+	Insert(ctx context.Context, house models.House) (insertedID string, err error)
 
-	Update(id string, houseUpdates models.House) (hasBeenUpdated bool, err error)
+	SelectByID(ctx context.Context, id string) (house models.House, found bool, err error)
+	SelectPage(ctx context.Context, query models.ListQuery) (houses []models.House, page models.Page, err error)
 
-	DeleteByID(id string) (hasBeenDeleted bool, err error)
+	Update(ctx context.Context, id string, houseUpdates models.House) (hasBeenUpdated bool, err error)
+
+	DeleteByID(ctx context.Context, id string) (hasBeenDeleted bool, err error)
+
+	InsertRoom(ctx context.Context, houseID string, room models.Room, maxRooms int) (hasBeenInserted bool, err error)
+	UpdateRoom(ctx context.Context, houseID string, roomID string, roomUpdates models.Room) (hasBeenUpdated bool, err error)
+	DeleteRoom(ctx context.Context, houseID string, roomID string) (hasBeenDeleted bool, err error)
+
+	InsertMember(ctx context.Context, houseID string, member models.HouseMember, maxMembers int) (hasBeenInserted bool, err error)
+	UpdateMember(ctx context.Context, houseID string, userID string, role string) (hasBeenUpdated bool, err error)
+	DeleteMember(ctx context.Context, houseID string, userID string) (hasBeenDeleted bool, err error)
+
+	UpdatePendingTransfer(ctx context.Context, houseID string, ownerID string, transfer *models.OwnershipTransfer) (hasBeenUpdated bool, err error)
+	TransferOwnership(ctx context.Context, houseID string, transfer models.OwnershipTransfer) (hasBeenTransferred bool, err error)
 }
 
 // NewHouseRepository returns a new house repository,
@@ -34,9 +47,21 @@ type houseRepository struct {
 	collection *mongo.Collection
 }
 
+// Creates the indexes of the collection
+// The houses of an user are found by their owner and their members
+func (f houseRepository) EnsureIndexes() error {
+	_, err := f.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"userID": 1}},
+		{Keys: bson.M{"members.userID": 1}},
+	})
+	return err
+}
+
 // Insert a house in database
-func (f houseRepository) Insert(house models.House) (string, error) {
-	insertOneResult, err := f.collection.InsertOne(context.TODO(), house)
+func (f houseRepository) Insert(ctx context.Context, house models.House) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.Insert", f.collection)
+	defer op.end(&err)
+	insertOneResult, err := f.collection.InsertOne(ctx, house)
 	if err != nil {
 		return "failed", err
 	}
@@ -45,56 +70,58 @@ func (f houseRepository) Insert(house models.House) (string, error) {
 }
 
 // Select a house by its id from database
-func (f houseRepository) SelectByID(id string) (house models.House, found bool) {
+func (f houseRepository) SelectByID(ctx context.Context, id string) (house models.House, found bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.SelectByID", f.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID}
-	err := f.collection.FindOne(context.TODO(), filter).Decode(&house)
+	err = f.collection.FindOne(ctx, filter).Decode(&house)
+	if err == mongo.ErrNoDocuments {
+		return models.House{}, false, nil // empty house object
+	}
 	if err != nil {
-		return models.House{}, false // empty house object
+		return models.House{}, false, err
 	}
-	return house, true
+	return house, true, nil
 }
 
-// Select houses by their userID from database
-// As an user can have several houses, this function will return all of them
-func (f houseRepository) SelectByUserID(userId string) ([]models.House, bool) {
-	filter := bson.M{"userID": userId}
-	findResult, err := f.collection.Find(context.TODO(), filter)
-	if err != nil {
-		return nil, false
-	}
-	var houses []models.House
-	err = findResult.All(context.TODO(), &houses)
-	if err != nil || len(houses) == 0 {
-		return nil, false
-	}
-	return houses, true
+// The fields which can filter or sort the lists of houses
+var houseListFields = map[string]listField{
+	"userID":       {expr: "$userID", zero: ""},
+	"name":         {expr: "$name", zero: ""},
+	"city":         {expr: "$city", zero: ""},
+	"totalSurface": {expr: bson.M{"$sum": "$rooms.surface"}, zero: 0},
+	"memberIDs":    {paths: []string{"userID", "members.userID"}}, // owner included, only filters
 }
 
-// Select several houses from the database
-// Use the limit parameter to limit the number of returned houses
-func (f houseRepository) SelectMany(limit int) ([]models.House, error) {
-	limit64 := int64(limit)
-	option := options.FindOptions{Limit: &limit64}
-	findResult, err := f.collection.Find(context.TODO(), bson.D{}, &option)
+// Select a page of houses from the database, see models.ListQuery
+// e.g. the houses shared with an user are the ones filtered by memberIDs
+func (f houseRepository) SelectPage(ctx context.Context, query models.ListQuery) (houses []models.House, page models.Page, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.SelectPage", f.collection)
+	defer op.end(&err)
+	docs, page, err := selectPage(ctx, f.collection, houseListFields, query)
 	if err != nil {
-		return nil, err
+		return nil, models.Page{}, err
 	}
-	var houses []models.House
-	err = findResult.All(context.TODO(), &houses)
-	if err != nil {
-		return nil, err
+	houses = make([]models.House, len(docs))
+	for key, doc := range docs {
+		err = bson.Unmarshal(doc, &houses[key])
+		if err != nil {
+			return nil, models.Page{}, err
+		}
 	}
-	return houses, nil
+	return houses, page, nil
 }
 
 // Updates a houses in database
 // Empty fields will not be updates (omitempty tag in model)
-func (f houseRepository) Update(id string, house models.House) (hasBeenUpdated bool, err error) {
+func (f houseRepository) Update(ctx context.Context, id string, house models.House) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.Update", f.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID}
 	update := bson.M{"$set": house}
-	updateResult := f.collection.FindOneAndUpdate(context.TODO(), filter, update)
+	updateResult := f.collection.FindOneAndUpdate(ctx, filter, update)
 	if updateResult.Err() != nil {
 		return false, updateResult.Err() // house not found
 	}
@@ -102,12 +129,161 @@ func (f houseRepository) Update(id string, house models.House) (hasBeenUpdated b
 }
 
 // Deletes a house from database
-func (f houseRepository) DeleteByID(id string) (bool, error) {
+func (f houseRepository) DeleteByID(ctx context.Context, id string) (hasBeenDeleted bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.DeleteByID", f.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID}
-	_, err := f.collection.DeleteOne(context.TODO(), filter)
+	_, err = f.collection.DeleteOne(ctx, filter)
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
+
+// Adds a room at the end of the rooms of a house
+// The room is not added if the house already has maxRooms rooms
+func (f houseRepository) InsertRoom(ctx context.Context, houseID string, room models.Room, maxRooms int) (hasBeenInserted bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.InsertRoom", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID, "rooms." + strconv.Itoa(maxRooms-1): bson.M{"$exists": false}}
+	update := bson.M{"$push": bson.M{"rooms": room}}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil
+}
+
+// Updates a room of a house, in place so that the other rooms are not overwritten
+// Empty fields will not be updated
+func (f houseRepository) UpdateRoom(ctx context.Context, houseID string, roomID string, room models.Room) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.UpdateRoom", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID, "rooms.id": roomID}
+	set := bson.M{}
+	if room.Name != "" {
+		set["rooms.$.name"] = room.Name
+	}
+	if room.Description != "" {
+		set["rooms.$.description"] = room.Description
+	}
+	if room.Surface != 0 {
+		set["rooms.$.surface"] = room.Surface
+	}
+	if len(set) == 0 { // nothing to update, only checks that the room exists
+		count, err := f.collection.CountDocuments(ctx, filter)
+		return count == 1, err
+	}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return updateResult.MatchedCount == 1, nil // room not found
+}
+
+// Removes a room from a house
+func (f houseRepository) DeleteRoom(ctx context.Context, houseID string, roomID string) (hasBeenDeleted bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.DeleteRoom", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID}
+	update := bson.M{"$pull": bson.M{"rooms": bson.M{"id": roomID}}}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil // room not found
+}
+
+// Adds a member to a house
+// The member is not added if he is already a member, or if the house already has maxMembers members
+func (f houseRepository) InsertMember(ctx context.Context, houseID string, member models.HouseMember, maxMembers int) (hasBeenInserted bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.InsertMember", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{
+		"_id":                                   objID,
+		"members.userID":                        bson.M{"$ne": member.UserID},
+		"members." + strconv.Itoa(maxMembers-1): bson.M{"$exists": false},
+	}
+	update := bson.M{"$push": bson.M{"members": member}}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil
+}
+
+// Changes the role of a member of a house
+func (f houseRepository) UpdateMember(ctx context.Context, houseID string, userID string, role string) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.UpdateMember", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID, "members.userID": userID}
+	update := bson.M{"$set": bson.M{"members.$.role": role}}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.MatchedCount == 1, nil // member not found
+}
+
+// Removes a member from a house
+func (f houseRepository) DeleteMember(ctx context.Context, houseID string, userID string) (hasBeenDeleted bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.DeleteMember", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID}
+	update := bson.M{"$pull": bson.M{"members": bson.M{"userID": userID}}}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil // member not found
+}
+
+// Sets the pending ownership transfer of a house, replacing the previous one, or removes it if transfer is nil
+// Nothing is changed if the owner of the house is not ownerID anymore
+func (f houseRepository) UpdatePendingTransfer(ctx context.Context, houseID string, ownerID string, transfer *models.OwnershipTransfer) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.UpdatePendingTransfer", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{"_id": objID, "userID": ownerID}
+	update := bson.M{"$unset": bson.M{"pendingTransfer": ""}}
+	if transfer != nil {
+		update = bson.M{"$set": bson.M{"pendingTransfer": transfer}}
+	}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.MatchedCount == 1, nil
+}
+
+// Makes the recipient of a pending and unexpired ownership transfer the owner of the house
+// He is removed from the members, and the previous owner loses his access
+// This is atomic, the transfer is only done if it is still the pending one
+func (f houseRepository) TransferOwnership(ctx context.Context, houseID string, transfer models.OwnershipTransfer) (hasBeenTransferred bool, err error) {
+	ctx, op := startOperation(ctx, "HouseRepository.TransferOwnership", f.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(houseID)
+	filter := bson.M{
+		"_id":                       objID,
+		"userID":                    transfer.FromUserID,
+		"pendingTransfer.toUserID":  transfer.ToUserID,
+		"pendingTransfer.expiresAt": bson.M{"$gt": time.Now()},
+	}
+	update := bson.M{
+		"$set":   bson.M{"userID": transfer.ToUserID},
+		"$unset": bson.M{"pendingTransfer": ""},
+		"$pull":  bson.M{"members": bson.M{"userID": transfer.ToUserID}},
+	}
+	updateResult, err := f.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// InvitationRepository handles the basic operations of an invitation entity/model.
+type InvitationRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, invitation models.Invitation) (insertedID string, err error)
+
+	SelectPendingOfHouse(ctx context.Context, houseID string) (invitations []models.Invitation, err error)
+	SelectPendingByTokenHash(ctx context.Context, tokenHash string) (invitation models.Invitation, found bool, err error)
+
+	UpdateStatus(ctx context.Context, houseID string, id string, status string) (hasBeenUpdated bool, err error)
+	RevokePendingOf(ctx context.Context, houseID string, email string) error
+	RevokePendingOfHouse(ctx context.Context, houseID string) error
+}
+
+// NewInvitationRepository returns a new invitation repository,
+// Requires the collection corresponding to invitations from the mongo database
+func NewInvitationRepository(collection *mongo.Collection) InvitationRepository {
+	return &invitationRepository{collection: collection}
+}
+
+// invitationRepository is a "InvitationRepository"
+// which manages the invitations using the mongoDB collection
+type invitationRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Hashes are unique and expired invitations are removed by mongo itself
+func (r invitationRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"tokenHash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "houseID", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter}},
+	})
+	return err
+}
+
+// Insert an invitation in database
+func (r invitationRepository) Insert(ctx context.Context, invitation models.Invitation) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, invitation)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// Select the pending and unexpired invitations of a house, the oldest first
+func (r invitationRepository) SelectPendingOfHouse(ctx context.Context, houseID string) (invitations []models.Invitation, err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.SelectPendingOfHouse", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"houseID": houseID, "status": models.InvitationPending, "expiresAt": bson.M{"$gt": time.Now()}}
+	findResult, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	invitations = []models.Invitation{}
+	err = findResult.All(ctx, &invitations)
+	if err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// Select a pending and unexpired invitation by the hash of its token
+func (r invitationRepository) SelectPendingByTokenHash(ctx context.Context, tokenHash string) (invitation models.Invitation, found bool, err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.SelectPendingByTokenHash", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"tokenHash": tokenHash, "status": models.InvitationPending, "expiresAt": bson.M{"$gt": time.Now()}}
+	err = r.collection.FindOne(ctx, filter).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return models.Invitation{}, false, nil
+	}
+	if err != nil {
+		return models.Invitation{}, false, err
+	}
+	return invitation, true, nil
+}
+
+// Sets the status of a pending invitation of a house, e.g. accepted
+// This is atomic, an invitation can only leave the pending status once
+func (r invitationRepository) UpdateStatus(ctx context.Context, houseID string, id string, status string) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.UpdateStatus", r.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "houseID": houseID, "status": models.InvitationPending}
+	update := bson.M{"$set": bson.M{"status": status}}
+	updateResult, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount == 1, nil // not found or not pending anymore
+}
+
+// Revokes the pending invitations of a house sent to an email address
+// Only the last sent invitation link stays valid
+func (r invitationRepository) RevokePendingOf(ctx context.Context, houseID string, email string) (err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.RevokePendingOf", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"houseID": houseID, "email": email, "status": models.InvitationPending}
+	update := bson.M{"$set": bson.M{"status": models.InvitationRevoked}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Revokes all the pending invitations of a house, e.g. when it is deleted
+func (r invitationRepository) RevokePendingOfHouse(ctx context.Context, houseID string) (err error) {
+	ctx, op := startOperation(ctx, "InvitationRepository.RevokePendingOfHouse", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"houseID": houseID, "status": models.InvitationPending}
+	update := bson.M{"$set": bson.M{"status": models.InvitationRevoked}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
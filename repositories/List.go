@@ -0,0 +1,266 @@
+package repositories
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"goapi/models"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor can not be decoded, or has been given by a list sorted another way
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// A field of a collection which can filter or sort its lists
+// expr is the path of the field, e.g. "$city", or an aggregation expression computing it
+// zero replaces the missing values when sorting, so that they can be compared with the keys of a cursor
+// paths are the stored fields of a field which can only filter, matching when one of them matches,
+// e.g. the owner and the members of a house, so that its filter can use their indexes
+type listField struct {
+	expr  interface{}
+	zero  interface{}
+	paths []string
+}
+
+// Computed fields and sort keys are added to the documents under these prefixes
+const (
+	computedFieldPrefix = "_list_"
+	sortKeyPrefix       = "_sort_"
+)
+
+// What an opaque cursor holds: the sort keys and the id of the document next to which its page starts,
+// and the order of the list which gave it
+type listCursor struct {
+	Sort     string        `json:"s"`
+	Backward bool          `json:"b,omitempty"`
+	Keys     []interface{} `json:"k"`
+	ID       string        `json:"i"`
+}
+
+// Selects a page of documents from a collection, and tells where the page is, see models.ListQuery
+// The documents are returned undecoded, with the sort keys added to them
+// Pages are found from the keys of the cursor rather than skipped, so that they stay consistent
+// when documents are inserted or deleted between two pages
+func selectPage(ctx context.Context, collection *mongo.Collection, fields map[string]listField, query models.ListQuery) (docs []bson.Raw, page models.Page, err error) {
+	order := sortSignature(query.Sort)
+	var from *listCursor
+	if query.Cursor != "" {
+		from, err = decodeCursor(query.Cursor, order, len(query.Sort))
+		if err != nil {
+			return nil, models.Page{}, err
+		}
+	}
+	backward := from != nil && from.Backward
+
+	// Filters, the ones on stored fields are matched first so that they can use the indexes,
+	// then the fields which are not a path are computed and matched
+	computed := bson.M{}
+	var storedConditions, computedConditions bson.A
+	for _, filter := range query.Filters {
+		field := fieldOf(fields, filter.Field)
+		path, ok := field.expr.(string)
+		switch {
+		case len(field.paths) > 0:
+			var or bson.A
+			for _, p := range field.paths {
+				or = append(or, filterCondition(p, filter))
+			}
+			storedConditions = append(storedConditions, bson.M{"$or": or})
+		case ok && strings.HasPrefix(path, "$"):
+			storedConditions = append(storedConditions, filterCondition(path[1:], filter))
+		default:
+			path = computedFieldPrefix + filter.Field
+			computed[path] = field.expr
+			computedConditions = append(computedConditions, filterCondition(path, filter))
+		}
+	}
+	var filterStages []bson.M
+	if len(storedConditions) > 0 {
+		filterStages = append(filterStages, bson.M{"$match": bson.M{"$and": storedConditions}})
+	}
+	if len(computed) > 0 {
+		filterStages = append(filterStages, bson.M{"$addFields": computed}, bson.M{"$match": bson.M{"$and": computedConditions}})
+	}
+
+	page.Total, err = countDocuments(ctx, collection, filterStages)
+	if err != nil {
+		return nil, models.Page{}, err
+	}
+
+	// Sort, backward pages are read in the reverse order then put back in the list order
+	keys := bson.M{}
+	sort := bson.D{}
+	for _, s := range query.Sort {
+		field := fieldOf(fields, s.Field)
+		keys[sortKeyPrefix+s.Field] = bson.M{"$ifNull": bson.A{field.expr, field.zero}}
+		sort = append(sort, bson.E{Key: sortKeyPrefix + s.Field, Value: sortDirection(s.Descending != backward)})
+	}
+	sort = append(sort, bson.E{Key: "_id", Value: sortDirection(backward)})
+
+	stages := append([]bson.M{}, filterStages...)
+	if len(keys) > 0 {
+		stages = append(stages, bson.M{"$addFields": keys})
+	}
+	if from != nil {
+		stages = append(stages, bson.M{"$match": afterCursor(*from, query.Sort)})
+	}
+	stages = append(stages, bson.M{"$sort": sort}, bson.M{"$limit": query.Limit + 1}) // one more to know if there is another page
+
+	aggregateResult, err := collection.Aggregate(ctx, stages)
+	if err != nil {
+		return nil, models.Page{}, err
+	}
+	defer aggregateResult.Close(ctx)
+	for aggregateResult.Next(ctx) {
+		docs = append(docs, append(bson.Raw{}, aggregateResult.Current...))
+	}
+	if err = aggregateResult.Err(); err != nil {
+		return nil, models.Page{}, err
+	}
+
+	hasMore := len(docs) > query.Limit
+	if hasMore {
+		docs = docs[:query.Limit]
+	}
+	if backward {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+	if len(docs) == 0 {
+		return docs, page, nil
+	}
+	first, last := docs[0], docs[len(docs)-1]
+	if backward {
+		page.Next = cursorOf(last, order, query.Sort, false)
+		if hasMore {
+			page.Prev = cursorOf(first, order, query.Sort, true)
+		}
+	} else {
+		if hasMore {
+			page.Next = cursorOf(last, order, query.Sort, false)
+		}
+		if from != nil {
+			page.Prev = cursorOf(first, order, query.Sort, true)
+		}
+	}
+	return docs, page, nil
+}
+
+func fieldOf(fields map[string]listField, name string) listField {
+	field, ok := fields[name]
+	if !ok {
+		panic("repositories: " + name + " can not filter or sort this list")
+	}
+	return field
+}
+
+// Counts the documents matching the filter stages
+func countDocuments(ctx context.Context, collection *mongo.Collection, filterStages []bson.M) (int64, error) {
+	stages := append(append([]bson.M{}, filterStages...), bson.M{"$count": "total"})
+	aggregateResult, err := collection.Aggregate(ctx, stages)
+	if err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		Total int64 `bson:"total"`
+	}
+	err = aggregateResult.All(ctx, &counts)
+	if err != nil || len(counts) == 0 { // no document matches
+		return 0, err
+	}
+	return counts[0].Total, nil
+}
+
+func filterCondition(path string, filter models.Filter) bson.M {
+	switch filter.Operator {
+	case models.FilterContains:
+		return bson.M{path: primitive.Regex{Pattern: regexp.QuoteMeta(fmt.Sprint(filter.Value)), Options: "i"}}
+	case models.FilterMin:
+		return bson.M{path: bson.M{"$gte": filter.Value}}
+	case models.FilterMax:
+		return bson.M{path: bson.M{"$lte": filter.Value}}
+	}
+	if filter.Value == false { // false is not stored, see the omitempty tags of the models
+		return bson.M{path: bson.M{"$ne": true}}
+	}
+	return bson.M{path: filter.Value}
+}
+
+func sortDirection(descending bool) int {
+	if descending {
+		return -1
+	}
+	return 1
+}
+
+// Matches the documents after the one of the cursor, in the order of the list, or before it for a backward cursor:
+// the ones with a greater first key, or the same first key and a greater second key, and so on until the id
+func afterCursor(from listCursor, sort []models.SortField) bson.M {
+	id, _ := primitive.ObjectIDFromHex(from.ID)
+	var or bson.A
+	for i := 0; i <= len(sort); i++ {
+		condition := bson.M{}
+		for j := 0; j < i; j++ {
+			condition[sortKeyPrefix+sort[j].Field] = from.Keys[j]
+		}
+		if i < len(sort) {
+			condition[sortKeyPrefix+sort[i].Field] = bson.M{comparison(sort[i].Descending != from.Backward): from.Keys[i]}
+		} else {
+			condition["_id"] = bson.M{comparison(from.Backward): id}
+		}
+		or = append(or, condition)
+	}
+	return bson.M{"$or": or}
+}
+
+func comparison(descending bool) string {
+	if descending {
+		return "$lt"
+	}
+	return "$gt"
+}
+
+// The order of a list, cursors are only valid in the order of the list which gave them, e.g. "-totalSurface,name"
+func sortSignature(sort []models.SortField) string {
+	fields := make([]string, len(sort))
+	for i, s := range sort {
+		fields[i] = s.Field
+		if s.Descending {
+			fields[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func cursorOf(doc bson.Raw, order string, sort []models.SortField, backward bool) string {
+	c := listCursor{Sort: order, Backward: backward, ID: doc.Lookup("_id").ObjectID().Hex(), Keys: []interface{}{}}
+	for _, s := range sort {
+		var key interface{}
+		_ = doc.Lookup(sortKeyPrefix + s.Field).Unmarshal(&key)
+		c.Keys = append(c.Keys, key)
+	}
+	encoded, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(encoded string, order string, keys int) (*listCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c listCursor
+	if json.Unmarshal(decoded, &c) != nil || c.Sort != order || len(c.Keys) != keys {
+		return nil, ErrInvalidCursor
+	}
+	if _, err = primitive.ObjectIDFromHex(c.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"goapi/models"
+	"reflect"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name     string
+		sort     []models.SortField
+		doc      bson.M
+		backward bool
+		wantKeys []interface{}
+	}{
+		{"by id", nil, bson.M{}, false, []interface{}{}},
+		{"by name", []models.SortField{{Field: "name"}}, bson.M{"_sort_name": "Flat"}, false, []interface{}{"Flat"}},
+		{"backward", []models.SortField{{Field: "name"}}, bson.M{"_sort_name": "Flat"}, true, []interface{}{"Flat"}},
+		{"several fields", []models.SortField{{Field: "totalSurface", Descending: true}, {Field: "city"}},
+			bson.M{"_sort_totalSurface": int32(120), "_sort_city": "Paris"}, false, []interface{}{float64(120), "Paris"}},
+		{"empty key", []models.SortField{{Field: "city"}}, bson.M{"_sort_city": ""}, false, []interface{}{""}},
+		{"unicode key", []models.SortField{{Field: "name"}}, bson.M{"_sort_name": "Maison d'été"}, false, []interface{}{"Maison d'été"}},
+	}
+	for _, test := range tests {
+		test.doc["_id"] = id
+		doc, err := bson.Marshal(test.doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order := sortSignature(test.sort)
+		cursor := cursorOf(doc, order, test.sort, test.backward)
+
+		decoded, err := decodeCursor(cursor, order, len(test.sort))
+		if err != nil {
+			t.Errorf("%s: decodeCursor: %v", test.name, err)
+			continue
+		}
+		if decoded.ID != id.Hex() || decoded.Backward != test.backward || decoded.Sort != order || !reflect.DeepEqual(decoded.Keys, test.wantKeys) {
+			t.Errorf("%s: decodeCursor = %+v, want id %s, backward %v, keys %v", test.name, *decoded, id.Hex(), test.backward, test.wantKeys)
+		}
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	sort := []models.SortField{{Field: "name"}}
+	doc, _ := bson.Marshal(bson.M{"_id": primitive.NewObjectID(), "_sort_name": "Flat"})
+	valid := cursorOf(doc, sortSignature(sort), sort, false)
+	tests := []struct {
+		name   string
+		cursor string
+		order  string
+		keys   int
+	}{
+		{"not base64", "not a cursor!", "name", 1},
+		{"not json", "bm90IGpzb24", "name", 1},
+		{"other order", valid, "-name", 1},
+		{"other keys count", valid, "name", 2},
+		{"invalid id", "eyJzIjoibmFtZSIsImsiOlsiRmxhdCJdLCJpIjoiMTIzIn0", "name", 1}, // {"s":"name","k":["Flat"],"i":"123"}
+	}
+	for _, test := range tests {
+		if _, err := decodeCursor(test.cursor, test.order, test.keys); err != ErrInvalidCursor {
+			t.Errorf("%s: decodeCursor = %v, want %v", test.name, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestAfterCursor(t *testing.T) {
+	id := primitive.NewObjectID()
+	sort := []models.SortField{{Field: "totalSurface", Descending: true}, {Field: "name"}}
+	got := afterCursor(listCursor{Keys: []interface{}{float64(120), "Flat"}, ID: id.Hex()}, sort)
+	want := bson.M{"$or": bson.A{
+		bson.M{"_sort_totalSurface": bson.M{"$lt": float64(120)}},
+		bson.M{"_sort_totalSurface": float64(120), "_sort_name": bson.M{"$gt": "Flat"}},
+		bson.M{"_sort_totalSurface": float64(120), "_sort_name": "Flat", "_id": bson.M{"$gt": id}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("afterCursor = %v, want %v", got, want)
+	}
+
+	// Backward, every comparison is reversed
+	got = afterCursor(listCursor{Backward: true, Keys: []interface{}{float64(120), "Flat"}, ID: id.Hex()}, sort)
+	want = bson.M{"$or": bson.A{
+		bson.M{"_sort_totalSurface": bson.M{"$gt": float64(120)}},
+		bson.M{"_sort_totalSurface": float64(120), "_sort_name": bson.M{"$lt": "Flat"}},
+		bson.M{"_sort_totalSurface": float64(120), "_sort_name": "Flat", "_id": bson.M{"$lt": id}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("backward afterCursor = %v, want %v", got, want)
+	}
+}
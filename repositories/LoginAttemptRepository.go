@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// LoginAttemptRepository handles the basic operations of a login attempt entity/model.
+type LoginAttemptRepository interface {
+	EnsureIndexes() error
+
+	SelectByKey(ctx context.Context, key string) (attempt models.LoginAttempt, found bool, err error)
+
+	Reserve(ctx context.Context, key string, failures int, at time.Time, expiresAt time.Time) (hasBeenReserved bool, err error)
+	Release(ctx context.Context, key string) error
+	Lock(ctx context.Context, key string, lockedUntil time.Time) error
+
+	DeleteByKey(ctx context.Context, key string) error
+}
+
+// NewLoginAttemptRepository returns a new login attempt repository,
+// Requires the collection corresponding to login attempts from the mongo database
+func NewLoginAttemptRepository(collection *mongo.Collection) LoginAttemptRepository {
+	return &loginAttemptRepository{collection: collection}
+}
+
+// loginAttemptRepository is a "LoginAttemptRepository"
+// which manages the login attempts using the mongoDB collection
+type loginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Old attempts are forgotten by mongo itself
+func (r loginAttemptRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter},
+	})
+	return err
+}
+
+// Select the login attempts of a key
+func (r loginAttemptRepository) SelectByKey(ctx context.Context, key string) (attempt models.LoginAttempt, found bool, err error) {
+	ctx, op := startOperation(ctx, "LoginAttemptRepository.SelectByKey", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"_id": key}
+	err = r.collection.FindOne(ctx, filter).Decode(&attempt)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.LoginAttempt{}, false, nil
+		}
+		return models.LoginAttempt{}, false, err
+	}
+	return attempt, true, nil
+}
+
+// Reserves a login attempt of a key, counted as a failure until it is released
+// failures is the number of failures the attempt was allowed with, 0 for a key without attempts
+// This is atomic, even across several instances: of concurrent attempts allowed with the same failures,
+// only one is reserved, the others return false
+func (r loginAttemptRepository) Reserve(ctx context.Context, key string, failures int, at time.Time, expiresAt time.Time) (hasBeenReserved bool, err error) {
+	ctx, op := startOperation(ctx, "LoginAttemptRepository.Reserve", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"_id": key, "failures": failures}
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"lastFailureAt": at, "expiresAt": expiresAt},
+	}
+	updateResult, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(failures == 0))
+	if isDuplicateKey(err) { // the key has been inserted by a concurrent attempt
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return updateResult.ModifiedCount > 0 || updateResult.UpsertedCount > 0, nil
+}
+
+// Releases a reserved login attempt of a key, which was not a failure
+func (r loginAttemptRepository) Release(ctx context.Context, key string) (err error) {
+	ctx, op := startOperation(ctx, "LoginAttemptRepository.Release", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"_id": key, "failures": bson.M{"$gt": 0}}
+	update := bson.M{"$inc": bson.M{"failures": -1}}
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Locks a key until the given time
+// The attempts are kept at least until the end of the lock
+func (r loginAttemptRepository) Lock(ctx context.Context, key string, lockedUntil time.Time) (err error) {
+	ctx, op := startOperation(ctx, "LoginAttemptRepository.Lock", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"_id": key}
+	update := bson.M{
+		"$set": bson.M{"lockedUntil": lockedUntil},
+		"$max": bson.M{"expiresAt": lockedUntil},
+	}
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// Forgets the login attempts of a key, used after a successful login or an unlock
+func (r loginAttemptRepository) DeleteByKey(ctx context.Context, key string) (err error) {
+	ctx, op := startOperation(ctx, "LoginAttemptRepository.DeleteByKey", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"_id": key}
+	_, err = r.collection.DeleteOne(ctx, filter)
+	return err
+}
+
+// Tells if an error of the driver is a duplicate key error of an unique index
+func isDuplicateKey(err error) bool {
+	const duplicateKeyCode = 11000
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, writeError := range e.WriteErrors {
+			if writeError.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == duplicateKeyCode
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"goapi/config"
+	"goapi/errors/errorDesc"
+	"goapi/tracing"
+	"time"
+)
+
+// ErrTimeout is returned, wrapping the driver error, when a database operation does not end before its deadline
+var ErrTimeout = errors.New(errorDesc.DatabaseTimeout)
+
+// IsTimeout tells if an error returned by a repository is caused by the database operation deadline
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// A database operation of a repository method, traced and bounded by the timeout set in the config
+type operation struct {
+	ctx    context.Context
+	span   *tracing.Span
+	cancel context.CancelFunc
+}
+
+// Starts the operation of a repository method, named after the repository and the method, e.g. UserRepository.SelectBy
+// The returned context carries its span and its deadline, it must be given to every call of the driver
+func startOperation(ctx context.Context, name string, collection *mongo.Collection) (context.Context, *operation) {
+	ctx, span := tracing.Start(ctx, name)
+	span.SetAttribute("db.system", "mongodb")
+	span.SetAttribute("db.name", collection.Database().Name())
+	span.SetAttribute("db.mongodb.collection", collection.Name())
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*time.Duration(config.Current.DatabaseOperationTimeoutInMilliseconds))
+	return ctx, &operation{ctx: ctx, span: span, cancel: cancel}
+}
+
+// Ends the operation, to defer with the error returned by the method
+// An error caused by the deadline is replaced by ErrTimeout, as the driver does not always wrap the context error
+func (o *operation) end(err *error) {
+	if *err != nil && o.ctx.Err() == context.DeadlineExceeded {
+		*err = fmt.Errorf("%w: %v", ErrTimeout, *err)
+	}
+	if *err != nil && *err != mongo.ErrNoDocuments {
+		o.span.RecordError(*err)
+	}
+	o.cancel()
+	o.span.End()
+}
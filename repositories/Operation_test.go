@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/config"
+	"goapi/models"
+	"testing"
+	"time"
+)
+
+// A database which never answers: nothing listens on its port, so the operations wait for a server until their deadline
+func unreachableDatabase(t *testing.T) *mongo.Database {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	return client.Database("goapi_test")
+}
+
+func TestOperationTimeout(t *testing.T) {
+	previous := config.Current.DatabaseOperationTimeoutInMilliseconds
+	config.Current.DatabaseOperationTimeoutInMilliseconds = 50
+	defer func() { config.Current.DatabaseOperationTimeoutInMilliseconds = previous }()
+	database := unreachableDatabase(t)
+	ctx := context.Background()
+
+	revocations := NewRevocationRepository(database.Collection("revocations"))
+	refreshTokens := NewRefreshTokenRepository(database.Collection("refresh_tokens"))
+	loginAttempts := NewLoginAttemptRepository(database.Collection("login_attempts"))
+	passwordResets := NewPasswordResetRepository(database.Collection("password_resets"))
+	emailVerifications := NewEmailVerificationRepository(database.Collection("email_verifications"))
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"IsRevoked", func() error { _, err := revocations.IsRevoked(ctx, "jti", "u1", time.Now()); return err }},
+		{"Insert revocation", func() error { _, err := revocations.Insert(ctx, models.Revocation{UserID: "u1"}); return err }},
+		{"SelectByHash", func() error { _, _, err := refreshTokens.SelectByHash(ctx, "hash"); return err }},
+		{"MarkUsed", func() error { _, err := refreshTokens.MarkUsed(ctx, "5f0000000000000000000000"); return err }},
+		{"SelectByKey", func() error { _, _, err := loginAttempts.SelectByKey(ctx, "ip:127.0.0.1"); return err }},
+		{"Reserve", func() error {
+			_, err := loginAttempts.Reserve(ctx, "ip:127.0.0.1", 0, time.Now(), time.Now())
+			return err
+		}},
+		{"Consume password reset", func() error { _, _, err := passwordResets.Consume(ctx, "hash"); return err }},
+		{"SelectLastOfUser", func() error { _, _, err := emailVerifications.SelectLastOfUser(ctx, "u1"); return err }},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		err := test.call()
+		if !IsTimeout(err) {
+			t.Errorf("%s = %v, want a timeout", test.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s took %v, want about 50ms", test.name, elapsed)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// PasswordResetRepository handles the basic operations of a password reset entity/model.
+type PasswordResetRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, reset models.PasswordReset) (insertedID string, err error)
+
+	Consume(ctx context.Context, tokenHash string) (reset models.PasswordReset, found bool, err error)
+
+	InvalidateAllOfUser(ctx context.Context, userID string) error
+}
+
+// NewPasswordResetRepository returns a new password reset repository,
+// Requires the collection corresponding to password resets from the mongo database
+func NewPasswordResetRepository(collection *mongo.Collection) PasswordResetRepository {
+	return &passwordResetRepository{collection: collection}
+}
+
+// passwordResetRepository is a "PasswordResetRepository"
+// which manages the password resets using the mongoDB collection
+type passwordResetRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Hashes are unique and expired resets are removed by mongo itself
+func (r passwordResetRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"tokenHash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"userID": 1}},
+		{Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter}},
+	})
+	return err
+}
+
+// Insert a password reset in database
+func (r passwordResetRepository) Insert(ctx context.Context, reset models.PasswordReset) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "PasswordResetRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, reset)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// Marks an unused and unexpired password reset as used and returns it
+// This is atomic, a token can only be consumed once
+func (r passwordResetRepository) Consume(ctx context.Context, tokenHash string) (reset models.PasswordReset, found bool, err error) {
+	ctx, op := startOperation(ctx, "PasswordResetRepository.Consume", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"tokenHash": tokenHash, "used": false, "expiresAt": bson.M{"$gt": time.Now()}}
+	update := bson.M{"$set": bson.M{"used": true}}
+	err = r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&reset)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.PasswordReset{}, false, nil
+		}
+		return models.PasswordReset{}, false, err
+	}
+	return reset, true, nil
+}
+
+// Marks every password reset of an user as used
+// Only the last requested reset link stays valid
+func (r passwordResetRepository) InvalidateAllOfUser(ctx context.Context, userID string) (err error) {
+	ctx, op := startOperation(ctx, "PasswordResetRepository.InvalidateAllOfUser", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"userID": userID, "used": false}
+	update := bson.M{"$set": bson.M{"used": true}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
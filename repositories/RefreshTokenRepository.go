@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// RefreshTokenRepository handles the basic operations of a refresh token entity/model.
+type RefreshTokenRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, token models.RefreshToken) (insertedID string, err error)
+
+	SelectByHash(ctx context.Context, tokenHash string) (token models.RefreshToken, found bool, err error)
+
+	MarkUsed(ctx context.Context, id string) (hasBeenMarked bool, err error)
+
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllOfUser(ctx context.Context, userID string) error
+}
+
+// NewRefreshTokenRepository returns a new refresh token repository,
+// Requires the collection corresponding to refresh tokens from the mongo database
+func NewRefreshTokenRepository(collection *mongo.Collection) RefreshTokenRepository {
+	return &refreshTokenRepository{collection: collection}
+}
+
+// refreshTokenRepository is a "RefreshTokenRepository"
+// which manages the refresh tokens using the mongoDB collection
+type refreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Hashes are unique and expired tokens are removed by mongo itself
+func (r refreshTokenRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"tokenHash": 1}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.M{"familyID": 1}},
+		{Keys: bson.M{"userID": 1}},
+		{Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter}},
+	})
+	return err
+}
+
+// Insert a refresh token in database
+func (r refreshTokenRepository) Insert(ctx context.Context, token models.RefreshToken) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "RefreshTokenRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, token)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// Select a refresh token by its hash
+// Used and revoked tokens are returned too, it is up to the caller to check them
+func (r refreshTokenRepository) SelectByHash(ctx context.Context, tokenHash string) (token models.RefreshToken, found bool, err error) {
+	ctx, op := startOperation(ctx, "RefreshTokenRepository.SelectByHash", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"tokenHash": tokenHash}
+	err = r.collection.FindOne(ctx, filter).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.RefreshToken{}, false, nil
+		}
+		return models.RefreshToken{}, false, err
+	}
+	return token, true, nil
+}
+
+// Marks a refresh token as used
+// This is atomic, only one of several concurrent calls for the same token will return true
+func (r refreshTokenRepository) MarkUsed(ctx context.Context, id string) (hasBeenMarked bool, err error) {
+	ctx, op := startOperation(ctx, "RefreshTokenRepository.MarkUsed", r.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "used": false, "revoked": false}
+	update := bson.M{"$set": bson.M{"used": true, "usedAt": time.Now()}}
+	updateResult := r.collection.FindOneAndUpdate(ctx, filter, update)
+	if updateResult.Err() != nil {
+		if updateResult.Err() == mongo.ErrNoDocuments {
+			return false, nil // already used or revoked
+		}
+		return false, updateResult.Err()
+	}
+	return true, nil
+}
+
+// Revokes every refresh token of a family
+func (r refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) (err error) {
+	ctx, op := startOperation(ctx, "RefreshTokenRepository.RevokeFamily", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"familyID": familyID}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Revokes every refresh token of an user
+func (r refreshTokenRepository) RevokeAllOfUser(ctx context.Context, userID string) (err error) {
+	ctx, op := startOperation(ctx, "RefreshTokenRepository.RevokeAllOfUser", r.collection)
+	defer op.end(&err)
+	filter := bson.M{"userID": userID}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
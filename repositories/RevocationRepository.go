@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"goapi/models"
+	"time"
+)
+
+// RevocationRepository handles the basic operations of a revocation entity/model.
+type RevocationRepository interface {
+	EnsureIndexes() error
+
+	Insert(ctx context.Context, revocation models.Revocation) (insertedID string, err error)
+
+	IsRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (revoked bool, err error)
+}
+
+// NewRevocationRepository returns a new revocation repository,
+// Requires the collection corresponding to revocations from the mongo database
+func NewRevocationRepository(collection *mongo.Collection) RevocationRepository {
+	return &revocationRepository{collection: collection}
+}
+
+// revocationRepository is a "RevocationRepository"
+// which manages the revocations using the mongoDB collection
+type revocationRepository struct {
+	collection *mongo.Collection
+}
+
+// Creates the indexes of the collection
+// Outdated revocations are removed by mongo itself
+func (r revocationRepository) EnsureIndexes() error {
+	expireAfter := int32(0)
+	_, err := r.collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.M{"jti": 1}},
+		{Keys: bson.M{"userID": 1}},
+		{Keys: bson.M{"expiresAt": 1}, Options: &options.IndexOptions{ExpireAfterSeconds: &expireAfter}},
+	})
+	return err
+}
+
+// Insert a revocation in database
+func (r revocationRepository) Insert(ctx context.Context, revocation models.Revocation) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "RevocationRepository.Insert", r.collection)
+	defer op.end(&err)
+	insertOneResult, err := r.collection.InsertOne(ctx, revocation)
+	if err != nil {
+		return "", err
+	}
+	return insertOneResult.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// Check if a token has been revoked, either by its jti or by a revocation of every token of its user
+// A token issued during the millisecond of a revocation of every token is revoked too
+func (r revocationRepository) IsRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (revoked bool, err error) {
+	ctx, op := startOperation(ctx, "RevocationRepository.IsRevoked", r.collection)
+	defer op.end(&err)
+	conditions := bson.A{
+		bson.M{"userID": userID, "jti": bson.M{"$exists": false}, "revokedBefore": bson.M{"$gte": issuedAt}},
+	}
+	if jti != "" {
+		conditions = append(conditions, bson.M{"jti": jti})
+	}
+	count, err := r.collection.CountDocuments(ctx, bson.M{"$or": conditions}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -2,28 +2,34 @@ package repositories
 
 import (
 	"context"
-	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"goapi/errors/errorDesc"
 	"goapi/models"
 )
 
 // UserRepository handles the basic operations of a user entity/model.
 type UserRepository interface {
-	Insert(user models.User) (string, error)
+	Insert(ctx context.Context, user models.User) (insertedID string, err error)
 
-	SelectBy(id string) (user models.User, found bool)
-	SelectForLogin(emailAddress string) (userID string, password string, salt string, err error)
-	SelectMany(limit int) ([]models.User, error)
+	SelectBy(ctx context.Context, id string) (user models.User, found bool, err error)
+	SelectIncludingDisabled(ctx context.Context, id string) (user models.User, found bool, err error)
+	SelectByEmail(ctx context.Context, emailAddress string) (user models.User, found bool, err error)
+	SelectForLogin(ctx context.Context, emailAddress string) (user models.User, err error)
+	SelectPage(ctx context.Context, query models.ListQuery) (users []models.User, page models.Page, err error)
 
-	Update(id string, userUpdates models.User) (hasBeenUpdated bool, err error)
+	Update(ctx context.Context, id string, userUpdates models.User) (hasBeenUpdated bool, err error)
+	UpdateEnabled(ctx context.Context, id string, enabled bool) (hasBeenUpdated bool, err error)
+	UpdateVerified(ctx context.Context, id string, verified bool) (hasBeenUpdated bool, err error)
+	UpdateRoles(ctx context.Context, id string, roles []string) (hasBeenUpdated bool, err error)
+	UpdateTOTP(ctx context.Context, id string, secret string, enabled bool, recoveryCodes []string) (hasBeenUpdated bool, err error)
+	ConsumeTOTPStep(ctx context.Context, id string, step int64) (consumed bool, err error)
+	ConsumeRecoveryCode(ctx context.Context, id string, recoveryCodeHash string) (consumed bool, err error)
 
-	DeleteBy(id string) (bool, error)
+	DeleteBy(ctx context.Context, id string) (hasBeenDeleted bool, err error)
 
-	EmailAddressExists(emailAddress string) (bool, error)
+	EmailAddressExists(ctx context.Context, emailAddress string) (exists bool, err error)
 }
 
 // NewUserRepository returns a new user repository,
@@ -39,8 +45,10 @@ type userCollectionRepository struct {
 }
 
 // Insert an user in database
-func (u userCollectionRepository) Insert(user models.User) (string, error) {
-	insertOneResult, err := u.collection.InsertOne(context.TODO(), user)
+func (u userCollectionRepository) Insert(ctx context.Context, user models.User) (insertedID string, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.Insert", u.collection)
+	defer op.end(&err)
+	insertOneResult, err := u.collection.InsertOne(ctx, user)
 	if err != nil {
 		return "", err
 	}
@@ -49,82 +57,225 @@ func (u userCollectionRepository) Insert(user models.User) (string, error) {
 }
 
 // Select and return an user by its ID
-func (u userCollectionRepository) SelectBy(id string) (user models.User, found bool) {
+func (u userCollectionRepository) SelectBy(ctx context.Context, id string) (user models.User, found bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.SelectBy", u.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID, "enabled": true}
-	err := u.collection.FindOne(context.TODO(), filter).Decode(&user)
+	err = u.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return models.User{}, false, nil // empty user object
+	}
 	if err != nil {
-		return models.User{}, false // empty user object
+		return models.User{}, false, err
 	}
 	user.Password = ""
-	return user, true
+	return user, true, nil
 }
 
-// Select an user by its email address
-// This method is used for the login method, it will return its password salt and userID
-func (u userCollectionRepository) SelectForLogin(emailAddress string) (userID string, password string, salt string, err error) {
-	user := struct {
-		ID       string `bson:"_id"`
-		Password string `bson:"password"`
-		Salt     string `bson:"salt"`
-	}{}
+// Select and return an user by its ID, even if it is disabled
+// It tells the disabled users apart from the deleted ones, which SelectBy does not find either
+func (u userCollectionRepository) SelectIncludingDisabled(ctx context.Context, id string) (user models.User, found bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.SelectIncludingDisabled", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	err = u.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return models.User{}, false, nil
+	}
+	if err != nil {
+		return models.User{}, false, err
+	}
+	user.Password = ""
+	return user, true, nil
+}
+
+// Select and return an enabled user by its email address
+func (u userCollectionRepository) SelectByEmail(ctx context.Context, emailAddress string) (user models.User, found bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.SelectByEmail", u.collection)
+	defer op.end(&err)
 	filter := bson.M{"email": emailAddress, "enabled": true}
-	err = u.collection.FindOne(context.TODO(), filter).Decode(&user)
+	err = u.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return models.User{}, false, nil // empty user object
+	}
 	if err != nil {
-		return "", "", "", err // empty user object
+		return models.User{}, false, err
 	}
-	return user.ID, user.Password, user.Salt, nil
+	user.Password = ""
+	return user, true, nil
 }
 
-// Select several users from the database
-// Use the limit parameter to limit the number of returned users
-func (u userCollectionRepository) SelectMany(limit int) ([]models.User, error) {
-	limit64 := int64(limit)
-	option := options.FindOptions{Limit: &limit64}
-	findResult, err := u.collection.Find(context.TODO(), bson.D{}, &option)
+// Select an user by its email address
+// This method is used for the login method, unlike the other ones it returns the password and salt of the user
+func (u userCollectionRepository) SelectForLogin(ctx context.Context, emailAddress string) (user models.User, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.SelectForLogin", u.collection)
+	defer op.end(&err)
+	filter := bson.M{"email": emailAddress, "enabled": true}
+	err = u.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		return nil, err
+		return models.User{}, err // empty user object
 	}
-	var users []models.User
-	err = findResult.All(context.TODO(), &users)
+	return user, nil
+}
+
+// The fields which can filter or sort the lists of users
+var userListFields = map[string]listField{
+	"firstName": {expr: "$firstName", zero: ""},
+	"lastName":  {expr: "$lastName", zero: ""},
+	"email":     {expr: "$email", zero: ""},
+	"language":  {expr: "$language", zero: ""},
+	"roles":     {expr: "$roles", zero: ""},
+	"verified":  {expr: "$verified", zero: false},
+	"enabled":   {expr: "$enabled", zero: false},
+}
+
+// Select a page of users from the database, see models.ListQuery
+func (u userCollectionRepository) SelectPage(ctx context.Context, query models.ListQuery) (users []models.User, page models.Page, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.SelectPage", u.collection)
+	defer op.end(&err)
+	docs, page, err := selectPage(ctx, u.collection, userListFields, query)
 	if err != nil {
-		return nil, err
+		return nil, models.Page{}, err
 	}
-	for key := range users {
+	users = make([]models.User, len(docs))
+	for key, doc := range docs {
+		err = bson.Unmarshal(doc, &users[key])
+		if err != nil {
+			return nil, models.Page{}, err
+		}
 		users[key].Password = ""
 	}
-	return users, nil
+	return users, page, nil
 }
 
 // Updates an user in database
 // Empty fields will not be updates (omitempty tag in model)
-func (u userCollectionRepository) Update(id string, user models.User) (hasBeenUpdated bool, err error) {
+func (u userCollectionRepository) Update(ctx context.Context, id string, user models.User) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.Update", u.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID, "enabled": true}
 	update := bson.M{"$set": user}
 
-	updateResult := u.collection.FindOneAndUpdate(context.TODO(), filter, update)
+	updateResult := u.collection.FindOneAndUpdate(ctx, filter, update)
 	if updateResult.Err() != nil {
 		return false, updateResult.Err() // user not found
 	}
 	return true, nil
 }
 
-// Deletes an user from database
-func (u userCollectionRepository) DeleteBy(id string) (bool, error) {
+// Enables or disables an user
+// The enabled field can not be updated with Update as false values are omitted
+// Disabled users are still found by this method so they can be enabled again
+func (u userCollectionRepository) UpdateEnabled(ctx context.Context, id string, enabled bool) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.UpdateEnabled", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID}
+	update := bson.M{"$set": bson.M{"enabled": enabled}}
+
+	updateResult := u.collection.FindOneAndUpdate(ctx, filter, update)
+	if updateResult.Err() != nil {
+		return false, updateResult.Err() // user not found
+	}
+	return true, nil
+}
+
+// Sets if the email address of an user is verified
+// The verified field can not be updated with Update as false values are omitted
+func (u userCollectionRepository) UpdateVerified(ctx context.Context, id string, verified bool) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.UpdateVerified", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "enabled": true}
+	update := bson.M{"$set": bson.M{"verified": verified}}
+
+	updateResult := u.collection.FindOneAndUpdate(ctx, filter, update)
+	if updateResult.Err() != nil {
+		return false, updateResult.Err() // user not found
+	}
+	return true, nil
+}
+
+// Replaces the roles of an user
+func (u userCollectionRepository) UpdateRoles(ctx context.Context, id string, roles []string) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.UpdateRoles", u.collection)
+	defer op.end(&err)
 	objID, _ := primitive.ObjectIDFromHex(id)
 	filter := bson.M{"_id": objID}
-	deleteOneResult, err := u.collection.DeleteOne(context.TODO(), filter)
+	update := bson.M{"$set": bson.M{"roles": roles}}
+
+	updateResult := u.collection.FindOneAndUpdate(ctx, filter, update)
+	if updateResult.Err() != nil {
+		return false, updateResult.Err() // user not found
+	}
+	return true, nil
+}
+
+// Sets the two-factor authentication of an user
+// An empty secret removes it with the recovery codes, whatever the other parameters
+func (u userCollectionRepository) UpdateTOTP(ctx context.Context, id string, secret string, enabled bool, recoveryCodes []string) (hasBeenUpdated bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.UpdateTOTP", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "enabled": true}
+	update := bson.M{"$set": bson.M{"totpSecret": secret, "totpEnabled": enabled, "recoveryCodes": recoveryCodes}}
+	if secret == "" {
+		update = bson.M{"$unset": bson.M{"totpSecret": "", "totpEnabled": "", "totpLastStep": "", "recoveryCodes": ""}}
+	}
+
+	updateResult := u.collection.FindOneAndUpdate(ctx, filter, update)
+	if updateResult.Err() != nil {
+		return false, updateResult.Err() // user not found
+	}
+	return true, nil
+}
+
+// Stores the time step of the last TOTP code used by an user
+// This is atomic and only succeeds if the step is newer than the last one, so a code can not be used twice
+func (u userCollectionRepository) ConsumeTOTPStep(ctx context.Context, id string, step int64) (consumed bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.ConsumeTOTPStep", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "enabled": true, "$or": bson.A{
+		bson.M{"totpLastStep": bson.M{"$lt": step}},
+		bson.M{"totpLastStep": bson.M{"$exists": false}},
+	}}
+	update := bson.M{"$set": bson.M{"totpLastStep": step}}
+	updateResult, err := u.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return false, err
 	}
-	if deleteOneResult.DeletedCount != 1 {
-		if deleteOneResult.DeletedCount == 0 {
-			return false, fmt.Errorf(errorDesc.ResourceNotFound)
-		}
-		return false, fmt.Errorf(errorDesc.Unknown) // Should never occur but just in case
+	return updateResult.ModifiedCount == 1, nil
+}
+
+// Removes a recovery code of an user
+// This is atomic, a recovery code can only be consumed once
+func (u userCollectionRepository) ConsumeRecoveryCode(ctx context.Context, id string, recoveryCodeHash string) (consumed bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.ConsumeRecoveryCode", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID, "enabled": true, "recoveryCodes": recoveryCodeHash}
+	update := bson.M{"$pull": bson.M{"recoveryCodes": recoveryCodeHash}}
+	updateResult, err := u.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
 	}
-	return true, nil
+	return updateResult.ModifiedCount == 1, nil
+}
+
+// Deletes an user from database
+func (u userCollectionRepository) DeleteBy(ctx context.Context, id string) (hasBeenDeleted bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.DeleteBy", u.collection)
+	defer op.end(&err)
+	objID, _ := primitive.ObjectIDFromHex(id)
+	filter := bson.M{"_id": objID}
+	deleteOneResult, err := u.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return deleteOneResult.DeletedCount == 1, nil // not deleted if not found
 }
 
 // Check if an email address already exists within the users
@@ -132,16 +283,18 @@ func (u userCollectionRepository) DeleteBy(id string) (bool, error) {
 // This method is used to prevent new users to register with an
 // already existing email address or users to update their email
 // address with already existing ones
-func (u userCollectionRepository) EmailAddressExists(email string) (bool, error) {
+func (u userCollectionRepository) EmailAddressExists(ctx context.Context, email string) (exists bool, err error) {
+	ctx, op := startOperation(ctx, "UserRepository.EmailAddressExists", u.collection)
+	defer op.end(&err)
 	filter := bson.M{"email": email}
 	limit64 := int64(5) // limit to 5 because the number is not relevant here
 	option := options.FindOptions{Limit: &limit64}
-	findResult, err := u.collection.Find(context.TODO(), filter, &option)
+	findResult, err := u.collection.Find(ctx, filter, &option)
 	if err != nil {
 		return false, err
 	}
 	var users []models.User
-	err = findResult.All(context.TODO(), &users)
+	err = findResult.All(ctx, &users)
 	if err != nil {
 		return false, err
 	}
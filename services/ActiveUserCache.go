@@ -0,0 +1,56 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Above this number of users, the expired ones are removed before adding a new one
+const activeUserCacheSweepSize = 10000
+
+// Remembers for a short time the users found enabled by Authenticate,
+// so that the user is not selected on every request
+type activeUserCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	until map[string]time.Time // by user id
+}
+
+// A zero ttl disables the cache
+func newActiveUserCache(ttl time.Duration) *activeUserCache {
+	return &activeUserCache{ttl: ttl, until: map[string]time.Time{}}
+}
+
+// Tells if the user has been found enabled less than ttl ago
+func (c *activeUserCache) contains(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until, ok := c.until[userID]
+	return ok && time.Now().Before(until)
+}
+
+func (c *activeUserCache) add(userID string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.until) >= activeUserCacheSweepSize {
+		for id, until := range c.until {
+			if !now.Before(until) {
+				delete(c.until, id)
+			}
+		}
+	}
+	if len(c.until) < activeUserCacheSweepSize {
+		c.until[userID] = now.Add(c.ttl)
+	}
+}
+
+// Forgets the user, e.g. once it is disabled
+func (c *activeUserCache) remove(userID string) {
+	c.mu.Lock()
+	delete(c.until, userID)
+	c.mu.Unlock()
+}
@@ -1,176 +1,775 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
-	"fmt"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"github.com/dgrijalva/jwt-go"
-	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"goapi/config"
-	"goapi/errors/errorCodes"
-	"goapi/errors/errorDesc"
+	"goapi/errors"
+	"goapi/logging"
+	"goapi/mailer"
+	"goapi/metrics"
+	"goapi/models"
 	"goapi/repositories"
+	"goapi/signing"
+	"goapi/tracing"
 	"golang.org/x/crypto/argon2"
 	"strings"
 	"time"
 )
 
 type AuthService interface {
-	Login(emailAddress string, password string) (credentialMatch bool, signedToken string, statusCode int, err error, errorCode string)
+	Login(ctx context.Context, emailAddress string, password string, ip string) (signedToken string, refreshToken string, mfaToken string, err error)
 
-	JwtGenerate(userID string) jwt.Token
-	JwtVerifyCanBeRefreshed(token *jwt.Token) bool
+	GenerateTokens(ctx context.Context, userID string, roles []string) (signedToken string, refreshToken string, err error)
+	JwtGenerate(userID string, roles []string) jwt.Token
+	MFAPendingTokenGenerate(userID string) (signedToken string, err error)
+	ParseMFAPendingToken(signedToken string) (userID string, err error)
 
-	ExtractJWTString(ctx *fiber.Ctx) (string, error)
-	ExtractJWT(ctx *fiber.Ctx) (*jwt.Token, error)
+	Authenticate(ctx context.Context, signedToken string) (principal models.Principal, err error)
 
-	JWTRefresh(ctx *fiber.Ctx) (jwt string, statusCode int, err error, errorCode string)
+	Refresh(ctx context.Context, refreshToken string) (signedToken string, newRefreshToken string, err error)
+
+	Logout(ctx context.Context, principal models.Principal, refreshToken string) error
+	LogoutAll(ctx context.Context, userID string) error
+	RevokeAllSessions(ctx context.Context, userID string) error
+
+	ForgotPassword(ctx context.Context, emailAddress string) error
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) error
+
+	SendEmailVerification(ctx context.Context, user models.User) error
+	ResendEmailVerification(ctx context.Context, userID string) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	IsEmailVerified(ctx context.Context, userID string) (bool, error)
+
+	UnlockAccount(ctx context.Context, caller models.Caller, userID string) error
 }
 
 // NewAuthService returns the default auth service.
-func NewAuthService(repo repositories.UserRepository) AuthService {
+func NewAuthService(repo repositories.UserRepository, refreshTokenRepo repositories.RefreshTokenRepository,
+	revocationRepo repositories.RevocationRepository, passwordResetRepo repositories.PasswordResetRepository,
+	emailVerificationRepo repositories.EmailVerificationRepository, loginAttemptRepo repositories.LoginAttemptRepository,
+	keySet *signing.KeySet, mailer mailer.Mailer) AuthService {
 	return &authService{
-		repo: repo,
+		keySet:                keySet,
+		repo:                  repo,
+		refreshTokenRepo:      refreshTokenRepo,
+		revocationRepo:        revocationRepo,
+		passwordResetRepo:     passwordResetRepo,
+		emailVerificationRepo: emailVerificationRepo,
+		loginAttemptRepo:      loginAttemptRepo,
+		mailer:                mailer,
+		activeUsers:           newActiveUserCache(time.Second * time.Duration(config.Current.AuthUserCacheInSeconds)),
 	}
 }
 
 type authService struct {
-	repo repositories.UserRepository
+	keySet                *signing.KeySet
+	repo                  repositories.UserRepository
+	refreshTokenRepo      repositories.RefreshTokenRepository
+	revocationRepo        repositories.RevocationRepository
+	passwordResetRepo     repositories.PasswordResetRepository
+	emailVerificationRepo repositories.EmailVerificationRepository
+	loginAttemptRepo      repositories.LoginAttemptRepository
+	mailer                mailer.Mailer
+	activeUsers           *activeUserCache
+}
+
+// Limits of the failed login attempts, for an account or an IP address
+type loginLimits struct {
+	freeAttempts int // failures allowed before any delay
+	maxAttempts  int // failures before the lockout
+}
+
+func accountLoginLimits() loginLimits {
+	return loginLimits{freeAttempts: config.Current.LoginFreeAttemptsPerAccount, maxAttempts: config.Current.LoginMaxAttemptsPerAccount}
+}
+
+func ipLoginLimits() loginLimits {
+	return loginLimits{freeAttempts: config.Current.LoginFreeAttemptsPerIP, maxAttempts: config.Current.LoginMaxAttemptsPerIP}
 }
 
 // Login method
 // From the emailAddress given by client, it will try to find the salt and password
 // from the user in the database
 // Provided password will then be hashed and salted
-// If it matches with the user password, a new JWT is sent
+// If it matches with the user password, a new JWT and a new refresh token are sent
+// If the user enabled two-factor authentication, a MFA pending token is sent instead, see MFAService.Verify
 //
 // NOTE: for optimal security the client application must always tells the user
 // "email or password incorrect", even if the user does not exists in database!
-func (a authService) Login(emailAddress string, providedPassword string) (bool, string, int, error, string) {
-	// Looks for the user salt and password in database
-	userID, userPassword, salt, err := a.repo.SelectForLogin(emailAddress)
+//
+// To prevent brute-force, failed attempts are counted by account and by IP address:
+// after a few failures each new attempt must wait an exponentially growing delay,
+// and after too many failures the account or IP address is locked for a while
+func (a authService) Login(ctx context.Context, emailAddress string, providedPassword string, ip string) (signedToken string, refreshToken string, mfaToken string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
+	signedToken, refreshToken, mfaToken, err = a.login(ctx, emailAddress, providedPassword, ip)
+	switch {
+	case err != nil:
+		metrics.LoginsTotal.Inc(metrics.ResultFailure, errors.From(err).Code)
+	case mfaToken != "":
+		metrics.LoginsTotal.Inc(metrics.ResultMFARequired, "")
+	default:
+		metrics.LoginsTotal.Inc(metrics.ResultSuccess, "")
+	}
+	return signedToken, refreshToken, mfaToken, err
+}
+
+// Login without the metrics
+func (a authService) login(ctx context.Context, emailAddress string, providedPassword string, ip string) (signedToken string, refreshToken string, mfaToken string, err error) {
+	accountKey := "account:" + strings.ToLower(emailAddress)
+	ipKey := "ip:" + ip
+	accountFailures, err := reserveLoginAttempt(ctx, a.loginAttemptRepo, accountKey, accountLoginLimits(), time.Now())
+	if err != nil {
+		return "", "", "", err
+	}
+	ipFailures, err := reserveLoginAttempt(ctx, a.loginAttemptRepo, ipKey, ipLoginLimits(), time.Now())
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return false, "", fiber.StatusUnauthorized, fmt.Errorf(errorDesc.CredentialDoesNotMatch), errorCodes.CredentialDoesNotMatch
+		if releaseErr := a.loginAttemptRepo.Release(ctx, accountKey); releaseErr != nil {
+			logging.FromContext(ctx).Error("login attempt not released", "error", releaseErr)
 		}
-		return false, "", fiber.StatusInternalServerError, err, errorCodes.InternalServerError
+		return "", "", "", err
+	}
+
+	// Looks for the user salt and password in database
+	user, err := a.repo.SelectForLogin(ctx, emailAddress)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return "", "", "", internalError(err)
 	}
 
 	// Check if pass are same, if not return error
-	if !verifyPasswordMatch(providedPassword, salt, userPassword) {
-		return false, "", fiber.StatusUnauthorized, fmt.Errorf(errorDesc.CredentialDoesNotMatch), errorCodes.CredentialDoesNotMatch
+	// Unknown email addresses are counted as failures too
+	if err == mongo.ErrNoDocuments || !verifyPasswordMatch(providedPassword, user.Salt, user.Password) {
+		err = lockIfTooManyFailures(ctx, a.loginAttemptRepo, accountKey, accountFailures, accountLoginLimits(), time.Now())
+		if err == nil {
+			err = lockIfTooManyFailures(ctx, a.loginAttemptRepo, ipKey, ipFailures, ipLoginLimits(), time.Now())
+		}
+		if err != nil {
+			return "", "", "", internalError(err)
+		}
+		return "", "", "", errors.CredentialDoesNotMatch
+	}
+	err = a.loginAttemptRepo.DeleteByKey(ctx, accountKey)
+	if err == nil {
+		err = a.loginAttemptRepo.Release(ctx, ipKey)
+	}
+	if err != nil {
+		return "", "", "", internalError(err)
+	}
+
+	// Checked once the password matches, so that it does not tell if an email address is registered
+	if config.Current.VerifiedEmailRequiredToLogin && !user.Verified {
+		return "", "", "", errors.EmailNotVerified
 	}
 
-	// Generates a new token for user
-	newToken := a.JwtGenerate(userID)
-	newTokenString, err := newToken.SignedString([]byte(config.HmacSampleSecret))
+	// Users with two-factor authentication only get a short-lived token to send with their code
+	if user.TOTPEnabled {
+		mfaToken, err = a.MFAPendingTokenGenerate(user.ID)
+		if err != nil {
+			return "", "", "", internalError(err)
+		}
+		return "", "", mfaToken, nil
+	}
+
+	// Generates new tokens for user
+	signedToken, refreshToken, err = a.GenerateTokens(ctx, user.ID, user.Roles)
 	if err != nil {
-		return false, "", fiber.StatusInternalServerError, err, errorCodes.InternalServerError
+		return "", "", "", internalError(err)
 	}
-	return true, newTokenString, fiber.StatusOK, nil, ""
+	return signedToken, refreshToken, "", nil
+}
+
+// Reserves a new login attempt for the key and returns the failures it counts, with this attempt
+// It is refused if the key is locked or if the delay since the last failure is not over
+// The attempt is counted as a failure as soon as it is reserved, so that a burst of concurrent attempts
+// can not pass before their failures are counted: only one of them is reserved, the others are throttled
+// It must be released, or the key deleted, if the attempt succeeds
+// The attempt is refused too if the attempts of the key can not be read, rather than let it through unchecked
+// The refusals tell how long to wait before the next attempt
+func reserveLoginAttempt(ctx context.Context, repo repositories.LoginAttemptRepository, key string, limits loginLimits, now time.Time) (failures int, err error) {
+	attempt, found, err := repo.SelectByKey(ctx, key)
+	if err != nil {
+		return 0, internalError(err)
+	}
+	if found {
+		if now.Before(attempt.LockedUntil) {
+			return 0, errors.LoginTemporarilyLocked.WithRetryAfter(attempt.LockedUntil.Sub(now))
+		}
+		if nextAttemptAt := attempt.LastFailureAt.Add(loginDelay(attempt.Failures, limits)); now.Before(nextAttemptAt) {
+			return 0, errors.LoginThrottled.WithRetryAfter(nextAttemptAt.Sub(now))
+		}
+	}
+	hasBeenReserved, err := repo.Reserve(ctx, key, attempt.Failures, now, now.Add(time.Hour*time.Duration(config.Current.LoginAttemptsMemoryInHours)))
+	if err != nil {
+		return 0, internalError(err)
+	}
+	if !hasBeenReserved { // reserved concurrently by another attempt, whose result is known soon
+		return 0, errors.LoginThrottled.WithRetryAfter(time.Second)
+	}
+	return attempt.Failures + 1, nil
+}
+
+// Locks the key of a failed login attempt if there were too many failures
+func lockIfTooManyFailures(ctx context.Context, repo repositories.LoginAttemptRepository, key string, failures int, limits loginLimits, now time.Time) error {
+	if failures >= limits.maxAttempts {
+		return repo.Lock(ctx, key, now.Add(time.Minute*time.Duration(config.Current.LoginLockoutDurationInMinutes)))
+	}
+	return nil
+}
+
+// Returns the delay to wait after the last failure before a new attempt
+// It doubles with each failure beyond the free attempts, up to the maximum delay
+func loginDelay(failures int, limits loginLimits) time.Duration {
+	if failures < limits.freeAttempts {
+		return 0
+	}
+	maxDelay := time.Second * time.Duration(config.Current.LoginMaxDelayInSeconds)
+	delay := time.Second * time.Duration(config.Current.LoginBaseDelayInSeconds)
+	for i := limits.freeAttempts; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// Generates a signed JWT and a refresh token starting a new family
+// Used after a login or a registration
+func (a authService) GenerateTokens(ctx context.Context, userID string, roles []string) (signedToken string, refreshToken string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GenerateTokens")
+	defer span.End()
+	newToken := a.JwtGenerate(userID, roles)
+	signedToken, err = a.keySet.Sign(&newToken)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = a.insertRefreshToken(ctx, userID, primitive.NewObjectID().Hex())
+	if err != nil {
+		return "", "", err
+	}
+	return signedToken, refreshToken, nil
 }
 
 // Generates a new JWT
-// A JWT contains the id of the user and the time it will expire which is calculated according
-// to the duration set in the jwtDuration.yml file
-func (a authService) JwtGenerate(userID string) jwt.Token {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+// A JWT contains the id of the user, its roles and the time it will expire which is calculated according
+// to the duration set in the config file
+// It also contains an unique id (jti) so that it can be revoked before it expires,
+// and its issue time in milliseconds (iat_ms), as a revocation of every token of the user must not spare
+// the ones issued during the same second
+// Its kid header tells which key of the key set signs it
+// Users stored before roles existed get the default roles
+func (a authService) JwtGenerate(userID string, roles []string) jwt.Token {
+	if len(roles) == 0 {
+		roles = models.DefaultRoles
+	}
+	now := time.Now()
+	token := a.keySet.NewToken(jwt.MapClaims{
 		//"generationTime": time.Now().Format(time.RFC3339),
-		"sub": userID,
-		"nbf": time.Now().Unix(),
-		"exp": time.Now().Add(time.Minute * time.Duration(config.JWTExpirationTimeInMinutes)).Unix(),
+		"jti":    primitive.NewObjectID().Hex(),
+		"sub":    userID,
+		"roles":  roles,
+		"iat":    now.Unix(),
+		"iat_ms": now.UnixNano() / int64(time.Millisecond),
+		"nbf":    now.Unix(),
+		"exp":    now.Add(time.Minute * time.Duration(config.Current.JWTExpirationTimeInMinutes)).Unix(),
 	})
-	/*
-		// Code to sign the token and convert it to string
-		tokenString, err := token.SignedString([]byte(config.HmacSampleSecret))
-		if err != nil {
-			return "", err
-		}*/
 	return *token
 }
 
-// Extract the JWT from the authorization header and returns it as a string
-// If no JWT were provided, returns an error
-func (a authService) ExtractJWTString(ctx *fiber.Ctx) (string, error) {
-	authHeader := ctx.Get("Authorization")
-	if authHeader == "" {
-		return "", fmt.Errorf(errorDesc.NoTokenWereProvided)
+// Generates and signs a MFA pending token
+// It proves that the user gave the right password, and is only accepted by MFAService.Verify
+// during the short duration set in the config file
+func (a authService) MFAPendingTokenGenerate(userID string) (string, error) {
+	now := time.Now()
+	token := a.keySet.NewToken(jwt.MapClaims{
+		"jti": primitive.NewObjectID().Hex(),
+		"sub": userID,
+		"mfa": "pending",
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Minute * time.Duration(config.Current.MFAPendingTokenExpirationTimeInMinutes)).Unix(),
+	})
+	return a.keySet.Sign(token)
+}
+
+// Parses a MFA pending token and returns its user id
+// An error is returned if it is not a valid and unexpired MFA pending token
+func (a authService) ParseMFAPendingToken(signedToken string) (userID string, err error) {
+	token, err := jwt.Parse(signedToken, a.keySet.Keyfunc)
+	if err != nil || !token.Valid {
+		return "", errors.MFATokenInvalid
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["mfa"] != "pending" {
+		return "", errors.MFATokenInvalid
 	}
+	userID, _ = claims["sub"].(string)
+	return userID, nil
+}
 
-	authHeaderParts := strings.Split(authHeader, " ")
-	if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
-		return "", fmt.Errorf(errorDesc.AuthorizationHeaderMustBeBearerToken)
+// Authenticate verifies a JWT sent to a restricted route and returns the principal of the request
+// Expired tokens are refused with jwtExpired, whether the refresh token is still valid is only known by /auth/refresh,
+// MFA pending tokens and revoked tokens are refused too,
+// and the user must still exist and be enabled, which is remembered during the duration set in the config file
+// The tokens of a disabled user are refused with accountDisabled, rather than as if the user was deleted
+func (a authService) Authenticate(ctx context.Context, signedToken string) (models.Principal, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Authenticate")
+	defer span.End()
+	token, err := jwt.Parse(signedToken, a.keySet.Keyfunc)
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors == jwt.ValidationErrorExpired {
+		if _, isMFAPending := token.Claims.(jwt.MapClaims)["mfa"]; isMFAPending {
+			return models.Principal{}, errors.MFATokenInvalid
+		}
+		return models.Principal{}, errors.JWTExpired
+	}
+	if err != nil || !token.Valid {
+		return models.Principal{}, errors.JWTInvalid
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if _, isMFAPending := claims["mfa"]; isMFAPending { // only accepted by MFAService.Verify
+		return models.Principal{}, errors.MFATokenInvalid
+	}
+	principal := principalOf(claims)
+	if principal.UserID == "" || principal.TokenID == "" {
+		return models.Principal{}, errors.JWTInvalid
 	}
 
-	return authHeaderParts[1], nil
+	revoked, err := a.revocationRepo.IsRevoked(ctx, principal.TokenID, principal.UserID, principal.IssuedAt)
+	if err != nil {
+		return models.Principal{}, internalError(err)
+	}
+	if revoked {
+		return models.Principal{}, errors.JWTRevoked
+	}
+	if a.activeUsers.contains(principal.UserID) {
+		return principal, nil
+	}
+	user, found, err := a.repo.SelectIncludingDisabled(ctx, principal.UserID)
+	if err != nil {
+		return models.Principal{}, internalError(err)
+	}
+	if !found {
+		return models.Principal{}, userGone
+	}
+	if !user.Enabled {
+		return models.Principal{}, errors.AccountDisabled
+	}
+	a.activeUsers.add(principal.UserID)
+	return principal, nil
 }
 
-// Extract the JWT from the authorization header and returns a jwt.Token object
-// If no JWT or a bad JWT were provided, returns an error
-// The token will be returned even if it is expired
-func (a authService) ExtractJWT(ctx *fiber.Ctx) (*jwt.Token, error) {
-	tokenString, err := a.ExtractJWTString(ctx)
+// Builds the principal from the claims of a verified JWT
+// Users stored before roles existed get the default roles
+func principalOf(claims jwt.MapClaims) models.Principal {
+	principal := models.Principal{}
+	principal.UserID, _ = claims["sub"].(string)
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			if r, ok := role.(string); ok {
+				principal.Roles = append(principal.Roles, r)
+			}
+		}
+	}
+	principal.TokenID, _ = claims["jti"].(string)
+	if issuedAtMs, ok := claims["iat_ms"].(float64); ok {
+		principal.IssuedAt = time.Unix(0, int64(issuedAtMs)*int64(time.Millisecond))
+	} else { // tokens generated before iat_ms existed
+		issuedAt, _ := claims["iat"].(float64)
+		principal.IssuedAt = time.Unix(int64(issuedAt), 0)
+	}
+	expiresAt, _ := claims["exp"].(float64)
+	principal.ExpiresAt = time.Unix(int64(expiresAt), 0)
+	return principal
+}
 
+// Refresh the JWT with a refresh token
+// The refresh token is rotated: it can only be used once and a new one of the same family is sent back
+// If an already used refresh token is presented again, it has probably been stolen,
+// so the whole family is revoked and the user will have to login again
+func (a authService) Refresh(ctx context.Context, refreshToken string) (signedToken string, newRefreshToken string, err error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Refresh")
+	defer span.End()
+	signedToken, newRefreshToken, err = a.refresh(ctx, refreshToken)
 	if err != nil {
-		return nil, err
+		metrics.TokenRefreshesTotal.Inc(metrics.ResultFailure, errors.From(err).Code)
+	} else {
+		metrics.TokenRefreshesTotal.Inc(metrics.ResultSuccess, "")
 	}
+	return signedToken, newRefreshToken, err
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf(errorDesc.TokenSignatureIsNotValid)
+// Refresh without the metrics
+func (a authService) refresh(ctx context.Context, refreshToken string) (signedToken string, newRefreshToken string, err error) {
+	if refreshToken == "" {
+		return "", "", errors.RequiredFieldEmpty
+	}
+	token, found, err := a.refreshTokenRepo.SelectByHash(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	if !found || token.Revoked {
+		return "", "", errors.RefreshTokenInvalid
+	}
+	if token.Used {
+		return a.revokeReusedFamily(ctx, token)
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return "", "", errors.RefreshTokenExpired
+	}
+	// The user is selected before the token is marked as used, so that a database error does not burn the token
+	user, found, err := a.repo.SelectIncludingDisabled(ctx, token.UserID)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	if !found || !user.Enabled {
+		_ = a.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+		if !found {
+			return "", "", userGone
 		}
-		return []byte(config.HmacSampleSecret), nil
+		return "", "", errors.AccountDisabled
+	}
+	hasBeenMarked, err := a.refreshTokenRepo.MarkUsed(ctx, token.ID)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	if !hasBeenMarked { // used concurrently by someone else
+		return a.revokeReusedFamily(ctx, token)
+	}
+
+	// From here token can be refreshed
+
+	newToken := a.JwtGenerate(user.ID, user.Roles) // roles are reloaded as they may have changed
+	signedToken, err = a.keySet.Sign(&newToken)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	newRefreshToken, err = a.insertRefreshToken(ctx, user.ID, token.FamilyID)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	return signedToken, newRefreshToken, nil
+}
+
+// Revokes the family of a refresh token presented a second time
+func (a authService) revokeReusedFamily(ctx context.Context, token models.RefreshToken) (string, string, error) {
+	err := a.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	return "", "", errors.RefreshTokenReused
+}
+
+// Generates a new refresh token for the family, stores its hash and returns it in clear
+func (a authService) insertRefreshToken(ctx context.Context, userID string, familyID string) (string, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	_, err = a.refreshTokenRepo.Insert(ctx, models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashOpaqueToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour * time.Duration(config.Current.JWTRefreshDeadlineInHours)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// Logout from the current session
+// The JWT is revoked until it expires, and the refresh token family too if a refresh token is given
+func (a authService) Logout(ctx context.Context, principal models.Principal, refreshToken string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.Logout")
+	defer span.End()
+	_, err := a.revocationRepo.Insert(ctx, models.Revocation{
+		JTI:           principal.TokenID,
+		UserID:        principal.UserID,
+		RevokedBefore: time.Now(),
+		ExpiresAt:     principal.ExpiresAt,
 	})
 	if err != nil {
-		if ve, ok := err.(*jwt.ValidationError); ok {
-			if ve.Errors != jwt.ValidationErrorExpired {
-				return nil, err // if the error is other than token is expired
+		return internalError(err)
+	}
+	if refreshToken != "" {
+		stored, found, err := a.refreshTokenRepo.SelectByHash(ctx, hashOpaqueToken(refreshToken))
+		if err != nil {
+			return internalError(err)
+		}
+		if found && stored.UserID == principal.UserID {
+			err = a.refreshTokenRepo.RevokeFamily(ctx, stored.FamilyID)
+			if err != nil {
+				return internalError(err)
 			}
 		}
 	}
-	return token, nil // returns the token even if it is expired
+	return nil
 }
 
-// Refresh the JWT
-// Provided JWT is extracted from context and analyzed
-// JWT will not be refreshed if it is still valid or goes beyond the refresh deadline
-func (a authService) JWTRefresh(ctx *fiber.Ctx) (tokenString string, statusCode int, err error, errorCode string) {
-	token, err := a.ExtractJWT(ctx)
+// Logout from every session of the user
+func (a authService) LogoutAll(ctx context.Context, userID string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.LogoutAll")
+	defer span.End()
+	err := a.RevokeAllSessions(ctx, userID)
 	if err != nil {
-		return "", fiber.StatusBadRequest, err, errorCodes.BadRequest
+		return internalError(err)
 	}
-	if token != nil && token.Valid { // Token is valid
-		return "", fiber.StatusBadRequest, fmt.Errorf(errorDesc.JWTIsStillValid), errorCodes.JWTIsStillValid
+	return nil
+}
+
+// Revokes every JWT and refresh token of an user issued until now, immediately
+// The revocation is precise to the millisecond, as mongo stores dates: tokens issued during the current
+// millisecond are revoked too, so it returns once this millisecond is over, and a login following it is not revoked
+// It is kept until the last revoked JWT expires
+func (a authService) RevokeAllSessions(ctx context.Context, userID string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.RevokeAllSessions")
+	defer span.End()
+	a.activeUsers.remove(userID)
+	now := time.Now()
+	revokedBefore := now.Truncate(time.Millisecond)
+	_, err := a.revocationRepo.Insert(ctx, models.Revocation{
+		UserID:        userID,
+		RevokedBefore: revokedBefore,
+		ExpiresAt:     now.Add(time.Minute * time.Duration(config.Current.JWTExpirationTimeInMinutes+1)),
+	})
+	if err != nil {
+		return err
 	}
+	err = a.refreshTokenRepo.RevokeAllOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	time.Sleep(time.Until(revokedBefore.Add(time.Millisecond)))
+	return nil
+}
 
-	canBeRefreshed := a.JwtVerifyCanBeRefreshed(token)
-	if !canBeRefreshed {
-		return "", fiber.StatusUnauthorized, fmt.Errorf(errorDesc.JWTExpiredCannotBeRefreshed), errorCodes.JWTExpiredCannotBeRefreshed
+// Forgotten password method
+// Generates a single use reset token, valid during the duration set in the config file, and mails it to the user in its language
+// Previous reset tokens of the user are invalidated
+//
+// NOTE: the same response is sent whether the user exists or not, so this can not be used to find users
+func (a authService) ForgotPassword(ctx context.Context, emailAddress string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ForgotPassword")
+	defer span.End()
+	if emailAddress == "" {
+		return errors.RequiredFieldEmpty
+	}
+	user, found, err := a.repo.SelectByEmail(ctx, emailAddress)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return nil
 	}
 
-	// From here token can be refreshed
-	userID := token.Claims.(jwt.MapClaims)["sub"].(string)
-	_, found := a.repo.SelectBy(userID)
-	if !found { // user not found, probably disabled
-		return "", fiber.StatusUnauthorized, err, errorCodes.ResourceNotFound
+	resetToken, err := generateOpaqueToken()
+	if err != nil {
+		return internalError(err)
+	}
+	err = a.passwordResetRepo.InvalidateAllOfUser(ctx, user.ID)
+	if err != nil {
+		return internalError(err)
+	}
+	now := time.Now()
+	_, err = a.passwordResetRepo.Insert(ctx, models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashOpaqueToken(resetToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Minute * time.Duration(config.Current.PasswordResetExpirationTimeInMinutes)),
+	})
+	if err != nil {
+		return internalError(err)
 	}
 
-	newToken := a.JwtGenerate(userID)
-	newTokenString, err := newToken.SignedString([]byte(config.HmacSampleSecret))
-	return newTokenString, fiber.StatusOK, nil, ""
+	message, err := mailer.NewMessage(mailer.PasswordResetTemplate, user, map[string]interface{}{
+		"Link":         config.Current.PasswordResetURL + resetToken,
+		"ValidMinutes": config.Current.PasswordResetExpirationTimeInMinutes,
+	})
+	if err == nil {
+		err = a.mailer.Send(message)
+	}
+	if err != nil { // not sent to the client, it would tell that the user exists
+		logging.FromContext(ctx).Error("password reset mail not sent", "userID", user.ID, "error", err)
+	}
+	return nil
 }
 
-// Verify that a JWT can be refreshed, according to the duration set in the jwtDuration.yml file
-func (a authService) JwtVerifyCanBeRefreshed(token *jwt.Token) bool {
-	claims := token.Claims.(jwt.MapClaims)
-	exp := int64(claims["exp"].(float64))
-	expirationTime := time.Unix(time.Now().Unix(), 0).Sub(time.Unix(exp, 0))
-	// println("expired from", expirationTime.String())
-	expirationDeadline := config.JWTRefreshDeadlineInHours * time.Hour
-	if expirationTime < expirationDeadline {
-		return true
+// Reset password method
+// Consumes the reset token sent by ForgotPassword and replaces the password of its user
+// Every existing session of the user is revoked
+func (a authService) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+	if resetToken == "" || newPassword == "" {
+		return errors.RequiredFieldEmpty
 	}
-	return false
+	reset, found, err := a.passwordResetRepo.Consume(ctx, hashOpaqueToken(resetToken))
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.PasswordResetTokenInvalid
+	}
+
+	salt, err := generateSalt(32)
+	if err != nil {
+		return internalError(err)
+	}
+	_, err = a.repo.Update(ctx, reset.UserID, models.User{
+		Salt:     string(salt),
+		Password: hashAndSalt([]byte(newPassword), salt),
+	})
+	if err == mongo.ErrNoDocuments { // user deleted or disabled since
+		return errors.PasswordResetTokenInvalid
+	}
+	if err != nil {
+		return internalError(err)
+	}
+
+	err = a.RevokeAllSessions(ctx, reset.UserID)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Sends a verification link to the email address of the user, in its language
+// Called after a registration or an email address change, previous verification links are invalidated
+func (a authService) SendEmailVerification(ctx context.Context, user models.User) error {
+	ctx, span := tracing.Start(ctx, "AuthService.SendEmailVerification")
+	defer span.End()
+	verificationToken, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+	err = a.emailVerificationRepo.InvalidateAllOfUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = a.emailVerificationRepo.Insert(ctx, models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashOpaqueToken(verificationToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour * time.Duration(config.Current.EmailVerificationExpirationTimeInHours)),
+	})
+	if err != nil {
+		return err
+	}
+	message, err := mailer.NewMessage(mailer.EmailVerificationTemplate, user, map[string]interface{}{
+		"Link":       config.Current.EmailVerificationURL + verificationToken,
+		"ValidHours": config.Current.EmailVerificationExpirationTimeInHours,
+	})
+	if err != nil {
+		return err
+	}
+	return a.mailer.Send(message)
+}
+
+// Sends a new verification link to the user
+// It can not be asked more than once during the delay set in the config file
+func (a authService) ResendEmailVerification(ctx context.Context, userID string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ResendEmailVerification")
+	defer span.End()
+	user, found, err := a.repo.SelectBy(ctx, userID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.ResourceNotFound
+	}
+	if user.Verified {
+		return errors.EmailAlreadyVerified
+	}
+	last, found, err := a.emailVerificationRepo.SelectLastOfUser(ctx, userID)
+	if err != nil {
+		return internalError(err)
+	}
+	resendDelay := time.Second * time.Duration(config.Current.EmailVerificationResendDelayInSeconds)
+	if found && time.Since(last.CreatedAt) < resendDelay {
+		return errors.EmailVerificationThrottled.WithRetryAfter(resendDelay - time.Since(last.CreatedAt))
+	}
+	err = a.SendEmailVerification(ctx, user)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Consumes a verification token and marks the email address of its user as verified
+// The token is refused if the user changed its email address since it was sent
+func (a authService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.VerifyEmail")
+	defer span.End()
+	if verificationToken == "" {
+		return errors.RequiredFieldEmpty
+	}
+	verification, found, err := a.emailVerificationRepo.Consume(ctx, hashOpaqueToken(verificationToken))
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.EmailVerificationTokenInvalid
+	}
+	user, found, err := a.repo.SelectBy(ctx, verification.UserID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found || user.Email != verification.Email {
+		return errors.EmailVerificationTokenInvalid
+	}
+	_, err = a.repo.UpdateVerified(ctx, user.ID, true)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Tells if the email address of an user is verified
+func (a authService) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.IsEmailVerified")
+	defer span.End()
+	user, found, err := a.repo.SelectBy(ctx, userID)
+	if err != nil {
+		return false, internalError(err)
+	}
+	if !found {
+		return false, userGone
+	}
+	return user.Verified, nil
+}
+
+// Unlocks the login of an user locked after too many failed attempts
+// Only an admin can do it
+func (a authService) UnlockAccount(ctx context.Context, caller models.Caller, userID string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.UnlockAccount")
+	defer span.End()
+	if !caller.IsAdmin() {
+		return errors.Forbidden
+	}
+	user, found, err := a.repo.SelectBy(ctx, userID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.ResourceNotFound
+	}
+	err = a.loginAttemptRepo.DeleteByKey(ctx, "account:"+strings.ToLower(user.Email))
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
 }
 
 // passwordSent: the unchanged password receive from request
@@ -189,8 +788,10 @@ func verifyPasswordMatch(passwordSent string, salt string, userPassword string)
 // This aims to prevent users from using throwable email addresses
 func validEmailAddress(email string) bool {
 	components := strings.Split(email, "@")
+	if len(components) != 2 {
+		return false
+	}
 	domain := components[1]
-	fmt.Printf("Domain: %s\n", domain)
 	if domain == "" {
 		return false
 	}
@@ -218,6 +819,23 @@ type argon2idParams struct {
 	keyLength   uint32
 }
 
+// Generate an opaque token, sent in clear to the client
+// 32 bytes from the CSPRNG, encoded in base64 url
+func generateOpaqueToken() (string, error) {
+	b, err := generateSalt(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash an opaque token to store it in database
+// Opaque tokens have enough entropy, a fast hash is enough and allows to look them up
+func hashOpaqueToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
 // Hash  and salt the password
 // Using Argon2id, one of the best current hash methods
 func hashAndSalt(password []byte, salt []byte) string {
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"goapi/errors"
+	"goapi/models"
+	"goapi/repositories"
+	"goapi/signing"
+	"sync"
+	"testing"
+	"time"
+)
+
+// In memory refresh tokens, err is returned by every method when set
+type fakeRefreshTokenRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*models.RefreshToken
+	err    error
+}
+
+func newFakeRefreshTokenRepo() *fakeRefreshTokenRepo {
+	return &fakeRefreshTokenRepo{tokens: map[string]*models.RefreshToken{}}
+}
+
+func (r *fakeRefreshTokenRepo) EnsureIndexes() error { return nil }
+
+func (r *fakeRefreshTokenRepo) Insert(_ context.Context, token models.RefreshToken) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return "", r.err
+	}
+	token.ID = primitive.NewObjectID().Hex()
+	r.tokens[token.ID] = &token
+	return token.ID, nil
+}
+
+func (r *fakeRefreshTokenRepo) SelectByHash(_ context.Context, tokenHash string) (models.RefreshToken, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return models.RefreshToken{}, false, r.err
+	}
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return *token, true, nil
+		}
+	}
+	return models.RefreshToken{}, false, nil
+}
+
+func (r *fakeRefreshTokenRepo) MarkUsed(_ context.Context, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok || token.Used || token.Revoked {
+		return false, nil
+	}
+	token.Used = true
+	return true, nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeFamily(_ context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeAllOfUser(_ context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.UserID == userID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+// In memory users, only the methods used by the tests are implemented
+type fakeUserRepo struct {
+	repositories.UserRepository
+	users map[string]models.User
+}
+
+func (r fakeUserRepo) SelectBy(_ context.Context, id string) (models.User, bool, error) {
+	user, ok := r.users[id]
+	if !ok || !user.Enabled {
+		return models.User{}, false, nil
+	}
+	return user, true, nil
+}
+
+func (r fakeUserRepo) SelectIncludingDisabled(_ context.Context, id string) (models.User, bool, error) {
+	user, ok := r.users[id]
+	return user, ok, nil
+}
+
+func newTestAuthService(users map[string]models.User, refreshTokenRepo repositories.RefreshTokenRepository) authService {
+	return authService{
+		keySet:           signing.NewHMACKeySet([]byte("test secret")),
+		repo:             fakeUserRepo{users: users},
+		refreshTokenRepo: refreshTokenRepo,
+		activeUsers:      newActiveUserCache(0),
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	tokens := newFakeRefreshTokenRepo()
+	a := newTestAuthService(map[string]models.User{"u1": {ID: "u1", Roles: []string{models.RoleUser}, Enabled: true}}, tokens)
+	first, err := a.insertRefreshToken(context.Background(), "u1", "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedToken, second, err := a.Refresh(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if signedToken == "" || second == "" || second == first {
+		t.Fatalf("Refresh = %q, %q, want a JWT and a new refresh token", signedToken, second)
+	}
+	_, third, err := a.Refresh(context.Background(), second)
+	if err != nil {
+		t.Fatalf("Refresh of the rotated token: %v", err)
+	}
+
+	// The first token is presented again: the family is revoked, even its last token
+	_, _, err = a.Refresh(context.Background(), first)
+	if err != errors.RefreshTokenReused {
+		t.Fatalf("Refresh of a used token = %v, want %v", err, errors.RefreshTokenReused)
+	}
+	_, _, err = a.Refresh(context.Background(), third)
+	if err != errors.RefreshTokenInvalid {
+		t.Fatalf("Refresh of a token of a revoked family = %v, want %v", err, errors.RefreshTokenInvalid)
+	}
+}
+
+func TestRefreshErrors(t *testing.T) {
+	users := map[string]models.User{"u1": {ID: "u1", Enabled: true}, "u3": {ID: "u3"}}
+	tests := []struct {
+		name    string
+		token   models.RefreshToken
+		present string
+		repoErr error
+		want    string
+	}{
+		{"empty", models.RefreshToken{}, "", nil, errors.RequiredFieldEmpty.Code},
+		{"unknown", models.RefreshToken{}, "unknown", nil, errors.RefreshTokenInvalid.Code},
+		{"revoked", models.RefreshToken{UserID: "u1", Revoked: true}, "revoked", nil, errors.RefreshTokenInvalid.Code},
+		{"expired", models.RefreshToken{UserID: "u1", ExpiresAt: time.Now().Add(-time.Minute)}, "expired", nil, errors.RefreshTokenExpired.Code},
+		{"user gone", models.RefreshToken{UserID: "u2", ExpiresAt: time.Now().Add(time.Hour)}, "gone", nil, userGone.Code},
+		{"user disabled", models.RefreshToken{UserID: "u3", ExpiresAt: time.Now().Add(time.Hour)}, "disabled", nil, errors.AccountDisabled.Code},
+		{"database error", models.RefreshToken{}, "any", stderrors.New("no server"), errors.InternalServerError.Code},
+		{"database timeout", models.RefreshToken{}, "any", repositories.ErrTimeout, errors.DatabaseTimeout.Code},
+	}
+	for _, test := range tests {
+		tokens := newFakeRefreshTokenRepo()
+		if test.token.UserID != "" {
+			test.token.FamilyID = "family"
+			test.token.TokenHash = hashOpaqueToken(test.present)
+			_, _ = tokens.Insert(context.Background(), test.token)
+		}
+		tokens.err = test.repoErr
+		a := newTestAuthService(users, tokens)
+		_, _, err := a.Refresh(context.Background(), test.present)
+		if errors.From(err).Code != test.want {
+			t.Errorf("%s: Refresh = %v, want %s", test.name, err, test.want)
+		}
+	}
+}
+
+// No revoked token
+type fakeRevocationRepo struct {
+	repositories.RevocationRepository
+}
+
+func (fakeRevocationRepo) IsRevoked(_ context.Context, _ string, _ string, _ time.Time) (bool, error) {
+	return false, nil
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	users := map[string]models.User{"u1": {ID: "u1", Enabled: true}, "u3": {ID: "u3"}}
+	tests := []struct {
+		name   string
+		userID string
+		want   string
+	}{
+		{"enabled", "u1", ""},
+		{"gone", "u2", userGone.Code},
+		{"disabled", "u3", errors.AccountDisabled.Code},
+	}
+	for _, test := range tests {
+		a := newTestAuthService(users, newFakeRefreshTokenRepo())
+		a.revocationRepo = fakeRevocationRepo{}
+		token := a.JwtGenerate(test.userID, nil)
+		signedToken, err := a.keySet.Sign(&token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		principal, err := a.Authenticate(context.Background(), signedToken)
+		if err != nil {
+			if got := errors.From(err).Code; got != test.want {
+				t.Errorf("%s: Authenticate = %v, want %s", test.name, err, test.want)
+			}
+		} else if test.want != "" || principal.UserID != test.userID {
+			t.Errorf("%s: Authenticate = %+v, want %s", test.name, principal, test.want)
+		}
+	}
+}
+
+func TestPrincipalIssuedAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   time.Time
+	}{
+		{"milliseconds", map[string]interface{}{"iat": float64(1600000000), "iat_ms": float64(1600000000123)}, time.Unix(1600000000, 123000000)},
+		{"seconds only", map[string]interface{}{"iat": float64(1600000000)}, time.Unix(1600000000, 0)},
+	}
+	for _, test := range tests {
+		if got := principalOf(test.claims).IssuedAt; !got.Equal(test.want) {
+			t.Errorf("%s: IssuedAt = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// In memory login attempts, Reserve only succeeds with the current failures of the key, like the mongo one
+type fakeLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+	err      error
+}
+
+func newFakeLoginAttemptRepo() *fakeLoginAttemptRepo {
+	return &fakeLoginAttemptRepo{attempts: map[string]models.LoginAttempt{}}
+}
+
+func (r *fakeLoginAttemptRepo) EnsureIndexes() error { return nil }
+
+func (r *fakeLoginAttemptRepo) SelectByKey(_ context.Context, key string) (models.LoginAttempt, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return models.LoginAttempt{}, false, r.err
+	}
+	attempt, ok := r.attempts[key]
+	return attempt, ok, nil
+}
+
+func (r *fakeLoginAttemptRepo) Reserve(_ context.Context, key string, failures int, at time.Time, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := r.attempts[key]
+	if attempt.Failures != failures {
+		return false, nil
+	}
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	attempt.ExpiresAt = expiresAt
+	r.attempts[key] = attempt
+	return true, nil
+}
+
+func (r *fakeLoginAttemptRepo) Release(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+		r.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) Lock(_ context.Context, key string, lockedUntil time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := r.attempts[key]
+	attempt.LockedUntil = lockedUntil
+	r.attempts[key] = attempt
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) DeleteByKey(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+var testLoginLimits = loginLimits{freeAttempts: 3, maxAttempts: 10}
+
+func TestLoginDelay(t *testing.T) {
+	// With the default config: 1 second after the free attempts, doubled at each failure up to 60 seconds
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{6, 8 * time.Second},
+		{8, 32 * time.Second},
+		{9, time.Minute},
+		{100, time.Minute},
+	}
+	for _, test := range tests {
+		if got := loginDelay(test.failures, testLoginLimits); got != test.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	tests := []struct {
+		name    string
+		attempt *models.LoginAttempt
+		repoErr error
+		want    error
+	}{
+		{"first attempt", nil, nil, nil},
+		{"free attempts", &models.LoginAttempt{Failures: 2, LastFailureAt: now}, nil, nil},
+		{"delay not over", &models.LoginAttempt{Failures: 4, LastFailureAt: now.Add(-time.Second)}, nil,
+			errors.LoginThrottled.WithRetryAfter(loginDelay(4, testLoginLimits) - time.Second)},
+		{"delay over", &models.LoginAttempt{Failures: 4, LastFailureAt: now.Add(-2 * time.Second)}, nil, nil},
+		{"locked", &models.LoginAttempt{Failures: 10, LastFailureAt: now.Add(-time.Hour), LockedUntil: now.Add(time.Minute)}, nil,
+			errors.LoginTemporarilyLocked.WithRetryAfter(time.Minute)},
+		{"lock over", &models.LoginAttempt{Failures: 10, LastFailureAt: now.Add(-time.Hour), LockedUntil: now.Add(-time.Minute)}, nil, nil},
+		{"database error", nil, stderrors.New("no server"), errors.InternalServerError},
+		{"database timeout", nil, repositories.ErrTimeout, errors.DatabaseTimeout},
+	}
+	for _, test := range tests {
+		repo := newFakeLoginAttemptRepo()
+		failures := 0
+		if test.attempt != nil {
+			repo.attempts["key"] = *test.attempt
+			failures = test.attempt.Failures
+		}
+		repo.err = test.repoErr
+		got, err := reserveLoginAttempt(context.Background(), repo, "key", testLoginLimits, now)
+		if errors.From(err).Code != errors.From(test.want).Code || errors.From(err).RetryAfter != errors.From(test.want).RetryAfter {
+			t.Errorf("%s: reserveLoginAttempt = %v, retry after %v, want %v, retry after %v",
+				test.name, err, errors.From(err).RetryAfter, test.want, errors.From(test.want).RetryAfter)
+			continue
+		}
+		if test.want == nil && (got != failures+1 || repo.attempts["key"].Failures != failures+1) {
+			t.Errorf("%s: reserveLoginAttempt = %d failures, %d stored, want %d", test.name, got, repo.attempts["key"].Failures, failures+1)
+		}
+	}
+}
+
+func TestReserveLoginAttemptBurst(t *testing.T) {
+	// Concurrent attempts at the same time: the ones after the free attempts must wait the delay
+	repo := newFakeLoginAttemptRepo()
+	now := time.Unix(1600000000, 0)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := reserveLoginAttempt(context.Background(), repo, "key", testLoginLimits, now); err == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed > testLoginLimits.freeAttempts || repo.attempts["key"].Failures != allowed {
+		t.Errorf("%d attempts allowed, %d failures counted, want at most %d allowed and all counted",
+			allowed, repo.attempts["key"].Failures, testLoginLimits.freeAttempts)
+	}
+}
+
+func TestLockIfTooManyFailures(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, failures := range []int{9, 10, 11} {
+		repo := newFakeLoginAttemptRepo()
+		if err := lockIfTooManyFailures(context.Background(), repo, "key", failures, testLoginLimits, now); err != nil {
+			t.Fatal(err)
+		}
+		locked := repo.attempts["key"].LockedUntil.After(now)
+		if locked != (failures >= testLoginLimits.maxAttempts) {
+			t.Errorf("lockIfTooManyFailures(%d) locked = %v", failures, locked)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"github.com/gofiber/fiber"
+	"go.mongodb.org/mongo-driver/mongo"
+	"goapi/errors"
+	"goapi/errors/errorCodes"
+	"goapi/errors/errorDesc"
+	"goapi/repositories"
+)
+
+// The errors returned by the services are *errors.APIError, sent as they are to the client by the error middleware
+
+// The user of a valid token does not exist anymore, it has probably been deleted
+var userGone = errors.New(fiber.StatusUnauthorized, errorCodes.ResourceNotFound, errorDesc.ResourceNotFound)
+
+// Returns the API error of an unexpected error, such as an error of a repository
+// Database timeouts are sent as such so that the client knows it can try again later
+func internalError(err error) error {
+	if repositories.IsTimeout(err) {
+		return errors.DatabaseTimeout.Wrap(err)
+	}
+	return errors.InternalServerError.Wrap(err)
+}
+
+// Returns the API error of an error of a repository update, which fails with mongo.ErrNoDocuments when nothing matches
+func updateError(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return errors.ResourceNotFound
+	}
+	return internalError(err)
+}
+
+// Returns the API error of an error of a repository list, which fails with repositories.ErrInvalidCursor
+// when the cursor of the page is not valid
+func listError(err error) error {
+	if err == repositories.ErrInvalidCursor {
+		return errors.CursorInvalid
+	}
+	return internalError(err)
+}
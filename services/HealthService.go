@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"goapi/logging"
+	"goapi/models"
+	"goapi/repositories"
+	"sync"
+	"time"
+)
+
+type HealthService interface {
+	Liveness() models.Health
+	Readiness() (ready bool, health models.Health)
+
+	SetStepResult(step string, err error)
+}
+
+// NewHealthService returns the default health service.
+// The startup steps are pending until their result is set, the server is not ready before they all succeed
+func NewHealthService(databaseRepo repositories.DatabaseRepository, pingTimeout time.Duration, steps ...string) HealthService {
+	s := &healthService{
+		databaseRepo: databaseRepo,
+		pingTimeout:  pingTimeout,
+		steps:        map[string]models.HealthCheck{},
+	}
+	for _, step := range steps {
+		s.steps[step] = models.HealthCheck{Status: models.HealthStatusPending}
+	}
+	return s
+}
+
+type healthService struct {
+	databaseRepo repositories.DatabaseRepository
+	pingTimeout  time.Duration
+	mu           sync.RWMutex
+	steps        map[string]models.HealthCheck
+}
+
+// The process is up as soon as it answers
+func (s *healthService) Liveness() models.Health {
+	return models.Health{Status: models.HealthStatusOK}
+}
+
+// The server is ready when MongoDB answers and every startup step succeeded
+// Errors are only logged, the probe is not authenticated and they could tell how the database is reached
+func (s *healthService) Readiness() (bool, models.Health) {
+	health := models.Health{Status: models.HealthStatusOK, Checks: map[string]models.HealthCheck{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.pingTimeout)
+	defer cancel()
+	if err := s.databaseRepo.Ping(ctx); err != nil {
+		logging.Warn("mongo ping failed", "error", err)
+		health.Checks["mongo"] = models.HealthCheck{Status: models.HealthStatusFail, Error: "unreachable"}
+	} else {
+		health.Checks["mongo"] = models.HealthCheck{Status: models.HealthStatusOK}
+	}
+
+	s.mu.RLock()
+	for step, check := range s.steps {
+		health.Checks[step] = check
+	}
+	s.mu.RUnlock()
+
+	for _, check := range health.Checks {
+		if check.Status != models.HealthStatusOK {
+			health.Status = models.HealthStatusFail
+			return false, health
+		}
+	}
+	return true, health
+}
+
+// Records the result of a startup step, a failed step can be set again when retried
+// The error is logged by the step itself, only the failure is sent by the probe
+func (s *healthService) SetStepResult(step string, err error) {
+	check := models.HealthCheck{Status: models.HealthStatusOK}
+	if err != nil {
+		check = models.HealthCheck{Status: models.HealthStatusFail, Error: "failed"}
+	}
+	s.mu.Lock()
+	s.steps[step] = check
+	s.mu.Unlock()
+}
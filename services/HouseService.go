@@ -1,91 +1,550 @@
 package services
 
 import (
-	"errors"
-	"github.com/gofiber/fiber"
-	"goapi/errors/errorCodes"
-	"goapi/errors/errorDesc"
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"goapi/config"
+	"goapi/errors"
+	"goapi/logging"
+	"goapi/mailer"
 	"goapi/models"
 	"goapi/repositories"
+	"goapi/tracing"
+	"time"
 )
 
 type HouseService interface {
-	Insert(models.House) (statusCode int, insertedHouseID string, err error, errorCode string)
+	Insert(ctx context.Context, house models.House) (insertedHouseID string, err error)
 
-	GetAll(limit int) ([]models.House, error)
-	GetByID(id string) (models.House, error)
-	GetByUserID(id string) ([]models.House, error)
+	GetAll(ctx context.Context, query models.ListQuery) (houses []models.House, page models.Page, err error)
+	GetByID(ctx context.Context, caller models.Caller, id string) (house models.House, err error)
+	GetByUserID(ctx context.Context, caller models.Caller, id string, query models.ListQuery) (houses []models.House, page models.Page, err error)
 
-	UpdateByID(id string, updates models.House) (statusCode int, hasBeenUpdated bool, err error, errorCode string)
+	UpdateByID(ctx context.Context, caller models.Caller, id string, updates models.House) error
 
-	DeleteByID(id string) (hasBeenDeleted bool, err error)
+	DeleteByID(ctx context.Context, caller models.Caller, id string) error
+
+	InsertRoom(ctx context.Context, caller models.Caller, houseID string, room models.Room) (insertedRoomID string, err error)
+	GetRooms(ctx context.Context, caller models.Caller, houseID string) (rooms []models.Room, err error)
+	GetRoom(ctx context.Context, caller models.Caller, houseID string, roomID string) (room models.Room, err error)
+	UpdateRoom(ctx context.Context, caller models.Caller, houseID string, roomID string, updates models.Room) error
+	DeleteRoom(ctx context.Context, caller models.Caller, houseID string, roomID string) error
+
+	GetMembers(ctx context.Context, caller models.Caller, houseID string) (members []models.HouseMember, err error)
+	InsertMember(ctx context.Context, caller models.Caller, houseID string, member models.HouseMember) error
+	UpdateMember(ctx context.Context, caller models.Caller, houseID string, userID string, role string) error
+	DeleteMember(ctx context.Context, caller models.Caller, houseID string, userID string) error
+
+	RequestTransfer(ctx context.Context, caller models.Caller, houseID string, toUserID string) error
+	AcceptTransfer(ctx context.Context, caller models.Caller, houseID string) error
+	CancelTransfer(ctx context.Context, caller models.Caller, houseID string) error
+	GetHistory(ctx context.Context, caller models.Caller, houseID string, query models.ListQuery) (events []models.HouseEvent, page models.Page, err error)
 }
 
+// Same limit as the validate tag of models.House
+const maxRoomsPerHouse = 100
+
+// Owner excluded
+const maxMembersPerHouse = 50
+
 // NewHouseService returns the default house service.
-func NewHouseService(houseRepo repositories.HouseRepository, userRepo repositories.UserRepository) HouseService {
+func NewHouseService(houseRepo repositories.HouseRepository, userRepo repositories.UserRepository, houseEventRepo repositories.HouseEventRepository, invitationRepo repositories.InvitationRepository, mailer mailer.Mailer) HouseService {
 	return &houseService{
-		houseRepo: houseRepo,
-		userRepo:  userRepo,
+		houseRepo:      houseRepo,
+		userRepo:       userRepo,
+		houseEventRepo: houseEventRepo,
+		invitationRepo: invitationRepo,
+		mailer:         mailer,
 	}
 }
 
 type houseService struct {
-	houseRepo repositories.HouseRepository
-	userRepo  repositories.UserRepository
+	houseRepo      repositories.HouseRepository
+	userRepo       repositories.UserRepository
+	houseEventRepo repositories.HouseEventRepository
+	invitationRepo repositories.InvitationRepository
+	mailer         mailer.Mailer
 }
 
 // Insert a house
 // This will first check if the userID provided exists in the database
-func (s *houseService) Insert(house models.House) (statusCode int, insertedHouseID string, err error, errorCode string) {
-	_, found := s.userRepo.SelectBy(house.UserID)
+func (s *houseService) Insert(ctx context.Context, house models.House) (insertedHouseID string, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.Insert")
+	defer span.End()
+	_, found, err := s.userRepo.SelectBy(ctx, house.UserID)
+	if err != nil {
+		return "", internalError(err)
+	}
 	if !found {
-		return fiber.StatusNotFound, "failed", errors.New(errorDesc.ResourceNotFound), errorCodes.ResourceNotFound
+		return "", errors.ResourceNotFound
 	}
-	insertedHouseID, err = s.houseRepo.Insert(house)
+	house.Members = nil
+	house.PendingTransfer = nil
+	setRoomIDs(house.Rooms)
+	insertedHouseID, err = s.houseRepo.Insert(ctx, house)
 	if err != nil {
-		return fiber.StatusConflict, "failed", errors.New(errorDesc.ResourceNotFound), errorCodes.ResourceNotFound
+		return "", internalError(err)
 	}
-	return fiber.StatusCreated, insertedHouseID, nil, ""
+	return insertedHouseID, nil
 }
 
-// Returns all houses
-// Use the limit parameter to limit the number of returned houses
-func (s *houseService) GetAll(limit int) ([]models.House, error) {
-	return s.houseRepo.SelectMany(limit)
+// Returns a page of all the houses
+func (s *houseService) GetAll(ctx context.Context, query models.ListQuery) (houses []models.House, page models.Page, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetAll")
+	defer span.End()
+	houses, page, err = s.houseRepo.SelectPage(ctx, query)
+	if err != nil {
+		return nil, models.Page{}, listError(err)
+	}
+	return houses, page, nil
 }
 
 // Returns a house by its id
-func (s *houseService) GetByID(id string) (models.House, error) {
-	house, found := s.houseRepo.SelectByID(id)
+// Its owner, its members and the admins can read it
+func (s *houseService) GetByID(ctx context.Context, caller models.Caller, id string) (house models.House, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetByID")
+	defer span.End()
+	return selectHouseWithRole(ctx, s.houseRepo, caller, id, models.HouseRoleViewer)
+}
+
+// Returns a page of the houses of an user: the ones he owns and the ones he is a member of, an empty page if he has none
+// Only the user himself or an admin can list them
+func (s *houseService) GetByUserID(ctx context.Context, caller models.Caller, id string, query models.ListQuery) (houses []models.House, page models.Page, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetByUserID")
+	defer span.End()
+	if !caller.CanAccess(id) {
+		return nil, models.Page{}, errors.Forbidden
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "memberIDs", Operator: models.FilterEqual, Value: id})
+	houses, page, err = s.houseRepo.SelectPage(ctx, query)
+	if err != nil {
+		return nil, models.Page{}, listError(err)
+	}
+	return houses, page, nil
+}
+
+// Tells the HouseRepository to update a house by its id
+// Its owner, its editors and the admins can update it, the owner is only changed by an ownership transfer, see RequestTransfer
+// Rooms given here replace all the rooms of the house, see UpdateRoom to update one of them
+func (s *houseService) UpdateByID(ctx context.Context, caller models.Caller, id string, updates models.House) error {
+	ctx, span := tracing.Start(ctx, "HouseService.UpdateByID")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, id, models.HouseRoleEditor)
+	if err != nil {
+		return err
+	}
+	if updates.UserID != "" && updates.UserID != house.UserID {
+		return errors.OwnershipTransferRequired
+	}
+	updates.UserID = ""
+	updates.Members = nil
+	updates.PendingTransfer = nil
+	setRoomIDs(updates.Rooms)
+	_, err = s.houseRepo.Update(ctx, id, updates)
+	if err != nil {
+		return updateError(err)
+	}
+	return nil
+}
+
+// Tells the HouseRepository to delete a house by its id
+// Only its owner or an admin can delete it
+// Its pending invitations are revoked first, so that they can not be accepted anymore, and its history is deleted with it
+func (s *houseService) DeleteByID(ctx context.Context, caller models.Caller, id string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.DeleteByID")
+	defer span.End()
+	_, err := selectHouseWithRole(ctx, s.houseRepo, caller, id, models.HouseRoleOwner)
+	if err != nil {
+		return err
+	}
+	err = s.invitationRepo.RevokePendingOfHouse(ctx, id)
+	if err != nil {
+		return internalError(err)
+	}
+	_, err = s.houseRepo.DeleteByID(ctx, id)
+	if err != nil {
+		return internalError(err)
+	}
+	err = s.houseEventRepo.DeleteByHouseID(ctx, id)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Select a house by its id and check that the caller has at least the minimum role in it, see models.HouseRoleAllows
+// A missing house is reported before a forbidden one, the existence of a house is not a secret
+func selectHouseWithRole(ctx context.Context, houseRepo repositories.HouseRepository, caller models.Caller, id string, minimumRole string) (house models.House, err error) {
+	house, found, err := houseRepo.SelectByID(ctx, id)
+	if err != nil {
+		return models.House{}, internalError(err)
+	}
 	if !found {
-		return house, errors.New(errorDesc.ResourceNotFound)
+		return models.House{}, errors.ResourceNotFound
+	}
+	if !models.HouseRoleAllows(callerRole(caller, house), minimumRole) {
+		return models.House{}, errors.Forbidden
 	}
 	return house, nil
 }
 
-// Returns houses of an user
-func (s *houseService) GetByUserID(id string) ([]models.House, error) {
-	houses, found := s.houseRepo.SelectByUserID(id)
+// Adds a member to a house, and tells why he has not been added: he is already a member or the house is full
+func insertHouseMember(ctx context.Context, houseRepo repositories.HouseRepository, houseID string, member models.HouseMember) error {
+	hasBeenInserted, err := houseRepo.InsertMember(ctx, houseID, member, maxMembersPerHouse)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenInserted { // added meanwhile, or the house is full
+		house, _, err := houseRepo.SelectByID(ctx, houseID)
+		if err != nil {
+			return internalError(err)
+		}
+		if house.RoleOf(member.UserID) != "" {
+			return errors.HouseMemberAlreadyExists
+		}
+		return errors.HouseMembersLimitReached
+	}
+	return nil
+}
+
+// Returns the role of the caller in a house, admins have the role of its owner
+func callerRole(caller models.Caller, house models.House) string {
+	if caller.IsAdmin() {
+		return models.HouseRoleOwner
+	}
+	return house.RoleOf(caller.UserID)
+}
+
+// Adds a room to a house, its id is generated
+// Its owner, its editors and the admins can add it
+func (s *houseService) InsertRoom(ctx context.Context, caller models.Caller, houseID string, room models.Room) (insertedRoomID string, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.InsertRoom")
+	defer span.End()
+	_, err = selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleEditor)
+	if err != nil {
+		return "", err
+	}
+	room.ID = primitive.NewObjectID().Hex()
+	hasBeenInserted, err := s.houseRepo.InsertRoom(ctx, houseID, room, maxRoomsPerHouse)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if !hasBeenInserted { // the house exists, so it is full
+		return "", errors.RoomsLimitReached
+	}
+	return room.ID, nil
+}
+
+// Returns the rooms of a house
+// Its owner, its members and the admins can read them
+func (s *houseService) GetRooms(ctx context.Context, caller models.Caller, houseID string) (rooms []models.Room, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetRooms")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if house.Rooms == nil {
+		return []models.Room{}, nil
+	}
+	return *house.Rooms, nil
+}
+
+// Returns a room of a house by its id
+// Its owner, its members and the admins can read it
+func (s *houseService) GetRoom(ctx context.Context, caller models.Caller, houseID string, roomID string) (room models.Room, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetRoom")
+	defer span.End()
+	rooms, err := s.GetRooms(ctx, caller, houseID)
+	if err != nil {
+		return models.Room{}, err
+	}
+	for _, room := range rooms {
+		if room.ID == roomID {
+			return room, nil
+		}
+	}
+	return models.Room{}, errors.ResourceNotFound
+}
+
+// Tells the HouseRepository to update a room of a house, the other rooms are kept as they are
+// Its owner, its editors and the admins can update it
+func (s *houseService) UpdateRoom(ctx context.Context, caller models.Caller, houseID string, roomID string, updates models.Room) error {
+	ctx, span := tracing.Start(ctx, "HouseService.UpdateRoom")
+	defer span.End()
+	_, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleEditor)
+	if err != nil {
+		return err
+	}
+	hasBeenUpdated, err := s.houseRepo.UpdateRoom(ctx, houseID, roomID, updates)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenUpdated {
+		return errors.ResourceNotFound
+	}
+	return nil
+}
+
+// Tells the HouseRepository to remove a room from a house
+// Its owner, its editors and the admins can remove it
+func (s *houseService) DeleteRoom(ctx context.Context, caller models.Caller, houseID string, roomID string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.DeleteRoom")
+	defer span.End()
+	_, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleEditor)
+	if err != nil {
+		return err
+	}
+	hasBeenDeleted, err := s.houseRepo.DeleteRoom(ctx, houseID, roomID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenDeleted {
+		return errors.ResourceNotFound
+	}
+	return nil
+}
+
+// Generates the ids of the rooms given without id, or with the id of a previous room
+func setRoomIDs(rooms *[]models.Room) {
+	if rooms == nil {
+		return
+	}
+	seen := map[string]bool{}
+	for i := range *rooms {
+		room := &(*rooms)[i]
+		if room.ID == "" || seen[room.ID] {
+			room.ID = primitive.NewObjectID().Hex()
+		}
+		seen[room.ID] = true
+	}
+}
+
+// Returns the users of a house, its owner first, then its members
+// Its owner, its members and the admins can read them
+func (s *houseService) GetMembers(ctx context.Context, caller models.Caller, houseID string) (members []models.HouseMember, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetMembers")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	members = []models.HouseMember{{UserID: house.UserID, Role: models.HouseRoleOwner}}
+	return append(members, house.Members...), nil
+}
+
+// Shares a house with an user, as an editor or a viewer
+// Only its owner or an admin can add members
+func (s *houseService) InsertMember(ctx context.Context, caller models.Caller, houseID string, member models.HouseMember) error {
+	ctx, span := tracing.Start(ctx, "HouseService.InsertMember")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return err
+	}
+	if house.RoleOf(member.UserID) != "" {
+		return errors.HouseMemberAlreadyExists
+	}
+	_, found, err := s.userRepo.SelectBy(ctx, member.UserID)
+	if err != nil {
+		return internalError(err)
+	}
 	if !found {
-		return houses, errors.New(errorDesc.ResourceNotFound)
+		return errors.ResourceNotFound
 	}
-	return houses, nil
+	return insertHouseMember(ctx, s.houseRepo, houseID, member)
 }
 
-// Tells the HouseRepository to update a house by its id
-func (s *houseService) UpdateByID(id string, updates models.House) (statusCode int, hasBeenUpdated bool, err error, errorCode string) {
-	hasBeenUpdated, err = s.houseRepo.Update(id, updates)
+// Changes the role of a member of a house
+// Only its owner or an admin can change it, the role of the owner can not be changed
+func (s *houseService) UpdateMember(ctx context.Context, caller models.Caller, houseID string, userID string, role string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.UpdateMember")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return err
+	}
+	if userID == house.UserID {
+		return errors.HouseOwnerCannotChange
+	}
+	hasBeenUpdated, err := s.houseRepo.UpdateMember(ctx, houseID, userID, role)
 	if err != nil {
-		return fiber.StatusNotFound, hasBeenUpdated, err, errorCodes.ResourceNotFound
+		return internalError(err)
+	}
+	if !hasBeenUpdated {
+		return errors.ResourceNotFound
 	}
-	return fiber.StatusOK, hasBeenUpdated, nil, ""
+	return nil
 }
 
-// Tells the HouseRepository to delete a house by its id
-func (s *houseService) DeleteByID(id string) (hasBeenDeleted bool, err error) {
-	hasBeenDeleted, err = s.houseRepo.DeleteByID(id)
-	if err != nil || !hasBeenDeleted {
-		return false, err
+// Removes a member from a house
+// Its owner or an admin can remove any member, and a member can leave the house, the owner can not be removed
+func (s *houseService) DeleteMember(ctx context.Context, caller models.Caller, houseID string, userID string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.DeleteMember")
+	defer span.End()
+	minimumRole := models.HouseRoleOwner
+	if userID == caller.UserID {
+		minimumRole = models.HouseRoleViewer
+	}
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, minimumRole)
+	if err != nil {
+		return err
+	}
+	if userID == house.UserID {
+		return errors.HouseOwnerCannotChange
+	}
+	hasBeenDeleted, err := s.houseRepo.DeleteMember(ctx, houseID, userID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenDeleted {
+		return errors.ResourceNotFound
+	}
+	return nil
+}
+
+// Asks another user to become the owner of a house, he is told by email and must accept it, see AcceptTransfer
+// A new transfer replaces the pending one, the transfer is recorded in the history of the house
+// Only its owner or an admin can transfer it
+func (s *houseService) RequestTransfer(ctx context.Context, caller models.Caller, houseID string, toUserID string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.RequestTransfer")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return err
+	}
+	if toUserID == house.UserID {
+		return errors.OwnershipTransferToOwner
+	}
+	recipient, found, err := s.userRepo.SelectBy(ctx, toUserID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.ResourceNotFound
+	}
+	now := time.Now()
+	transfer := models.OwnershipTransfer{
+		FromUserID: house.UserID,
+		ToUserID:   toUserID,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(time.Hour * time.Duration(config.Current.OwnershipTransferExpirationTimeInHours)),
+	}
+	hasBeenUpdated, err := s.houseRepo.UpdatePendingTransfer(ctx, houseID, house.UserID, &transfer)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenUpdated { // deleted or transferred meanwhile
+		return errors.ResourceNotFound
+	}
+	s.recordEvent(ctx, models.HouseEvent{HouseID: houseID, Type: models.HouseEventTransferRequested, UserID: caller.UserID, FromUserID: transfer.FromUserID, ToUserID: transfer.ToUserID, At: now})
+
+	// The transfer stays pending if the mail can not be sent, it can be requested again
+	err = s.sendTransferRequest(ctx, house, recipient)
+	if err != nil {
+		logging.FromContext(ctx).Error("ownership transfer mail not sent", "houseID", houseID, "error", err)
+	}
+	return nil
+}
+
+// Accepts the pending ownership transfer of a house, the caller becomes its owner
+// Only the recipient of the transfer can accept it, before it expires
+func (s *houseService) AcceptTransfer(ctx context.Context, caller models.Caller, houseID string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.AcceptTransfer")
+	defer span.End()
+	house, found, err := s.houseRepo.SelectByID(ctx, houseID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.ResourceNotFound
+	}
+	transfer := house.PendingTransfer
+	if transfer == nil || transfer.ToUserID != caller.UserID || !time.Now().Before(transfer.ExpiresAt) {
+		return errors.OwnershipTransferNotPending
+	}
+	hasBeenTransferred, err := s.houseRepo.TransferOwnership(ctx, houseID, *transfer)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenTransferred { // cancelled or replaced meanwhile
+		return errors.OwnershipTransferNotPending
+	}
+	s.recordEvent(ctx, models.HouseEvent{HouseID: houseID, Type: models.HouseEventTransferred, UserID: caller.UserID, FromUserID: transfer.FromUserID, ToUserID: transfer.ToUserID, At: time.Now()})
+	return nil
+}
+
+// Cancels the pending ownership transfer of a house
+// Its owner or an admin can cancel it, and its recipient can decline it
+func (s *houseService) CancelTransfer(ctx context.Context, caller models.Caller, houseID string) error {
+	ctx, span := tracing.Start(ctx, "HouseService.CancelTransfer")
+	defer span.End()
+	house, found, err := s.houseRepo.SelectByID(ctx, houseID)
+	if err != nil {
+		return internalError(err)
+	}
+	if !found {
+		return errors.ResourceNotFound
+	}
+	transfer := house.PendingTransfer
+	isRecipient := transfer != nil && transfer.ToUserID == caller.UserID
+	if !isRecipient && !models.HouseRoleAllows(callerRole(caller, house), models.HouseRoleOwner) {
+		return errors.Forbidden
+	}
+	if transfer == nil {
+		return errors.OwnershipTransferNotPending
+	}
+	hasBeenUpdated, err := s.houseRepo.UpdatePendingTransfer(ctx, houseID, house.UserID, nil)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenUpdated {
+		return errors.OwnershipTransferNotPending
+	}
+	s.recordEvent(ctx, models.HouseEvent{HouseID: houseID, Type: models.HouseEventTransferCancelled, UserID: caller.UserID, FromUserID: transfer.FromUserID, ToUserID: transfer.ToUserID, At: time.Now()})
+	return nil
+}
+
+// Returns a page of the history of a house, e.g. its ownership transfers
+// Its owner, its members and the admins can read it
+func (s *houseService) GetHistory(ctx context.Context, caller models.Caller, houseID string, query models.ListQuery) (events []models.HouseEvent, page models.Page, err error) {
+	ctx, span := tracing.Start(ctx, "HouseService.GetHistory")
+	defer span.End()
+	_, err = selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleViewer)
+	if err != nil {
+		return nil, models.Page{}, err
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "houseID", Operator: models.FilterEqual, Value: houseID})
+	events, page, err = s.houseEventRepo.SelectPage(ctx, query)
+	if err != nil {
+		return nil, models.Page{}, listError(err)
+	}
+	return events, page, nil
+}
+
+// Records an event in the history of a house
+// The change is already done, so an event which can not be recorded is only logged
+func (s *houseService) recordEvent(ctx context.Context, event models.HouseEvent) {
+	_, err := s.houseEventRepo.Insert(ctx, event)
+	if err != nil {
+		logging.FromContext(ctx).Error("house event not recorded", "houseID", event.HouseID, "type", event.Type, "error", err)
+	}
+}
+
+// Tells the recipient of an ownership transfer that he can accept it, in his language
+func (s *houseService) sendTransferRequest(ctx context.Context, house models.House, recipient models.User) error {
+	owner, _, err := s.userRepo.SelectBy(ctx, house.UserID)
+	if err != nil {
+		return err
+	}
+	message, err := mailer.NewMessage(mailer.OwnershipTransferTemplate, recipient, map[string]interface{}{
+		"Owner":      owner,
+		"House":      house,
+		"Link":       config.Current.OwnershipTransferURL + house.ID,
+		"ValidHours": config.Current.OwnershipTransferExpirationTimeInHours,
+	})
+	if err != nil {
+		return err
 	}
-	return true, nil
+	return s.mailer.Send(message)
 }
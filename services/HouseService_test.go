@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"goapi/errors"
+	"goapi/models"
+	"goapi/repositories"
+	"testing"
+)
+
+// In memory houses, only the methods used by the tests are implemented
+type fakeHouseRepo struct {
+	repositories.HouseRepository
+	houses map[string]models.House
+}
+
+func (r fakeHouseRepo) SelectByID(_ context.Context, id string) (models.House, bool, error) {
+	house, ok := r.houses[id]
+	return house, ok, nil
+}
+
+func (r fakeHouseRepo) DeleteByID(_ context.Context, id string) (bool, error) {
+	_, ok := r.houses[id]
+	delete(r.houses, id)
+	return ok, nil
+}
+
+// Records the houses whose pending invitations are revoked
+type fakeInvitationRepo struct {
+	repositories.InvitationRepository
+	revokedHouses []string
+}
+
+func (r *fakeInvitationRepo) RevokePendingOfHouse(_ context.Context, houseID string) error {
+	r.revokedHouses = append(r.revokedHouses, houseID)
+	return nil
+}
+
+// Records the houses whose history is deleted
+type fakeHouseEventRepo struct {
+	repositories.HouseEventRepository
+	deletedHouses []string
+}
+
+func (r *fakeHouseEventRepo) DeleteByHouseID(_ context.Context, houseID string) error {
+	r.deletedHouses = append(r.deletedHouses, houseID)
+	return nil
+}
+
+func TestDeleteHouse(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  models.Caller
+		houseID string
+		want    error
+		deleted bool
+	}{
+		{"owner", models.Caller{UserID: "owner"}, "h1", nil, true},
+		{"admin", models.Caller{UserID: "admin", Roles: []string{models.RoleAdmin}}, "h1", nil, true},
+		{"editor", models.Caller{UserID: "editor"}, "h1", errors.Forbidden, false},
+		{"missing house", models.Caller{UserID: "owner"}, "h2", errors.ResourceNotFound, false},
+	}
+	for _, test := range tests {
+		houses := fakeHouseRepo{houses: map[string]models.House{"h1": {
+			ID:      "h1",
+			UserID:  "owner",
+			Members: []models.HouseMember{{UserID: "editor", Role: models.HouseRoleEditor}},
+		}}}
+		invitations := &fakeInvitationRepo{}
+		events := &fakeHouseEventRepo{}
+		s := NewHouseService(houses, fakeUserRepo{}, events, invitations, nil)
+
+		err := s.DeleteByID(context.Background(), test.caller, test.houseID)
+		if err != test.want {
+			t.Errorf("%s: DeleteByID = %v, want %v", test.name, err, test.want)
+		}
+		_, remains := houses.houses["h1"]
+		revoked := len(invitations.revokedHouses) == 1 && invitations.revokedHouses[0] == "h1"
+		historyDeleted := len(events.deletedHouses) == 1 && events.deletedHouses[0] == "h1"
+		if remains == test.deleted || revoked != test.deleted || historyDeleted != test.deleted {
+			t.Errorf("%s: house deleted %v, invitations revoked %v, history deleted %v, want %v",
+				test.name, !remains, revoked, historyDeleted, test.deleted)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"goapi/config"
+	"goapi/errors"
+	"goapi/logging"
+	"goapi/mailer"
+	"goapi/models"
+	"goapi/repositories"
+	"goapi/tracing"
+	"strings"
+	"time"
+)
+
+type InvitationService interface {
+	Insert(ctx context.Context, caller models.Caller, houseID string, invitation models.Invitation) (insertedInvitationID string, err error)
+	GetPendingOfHouse(ctx context.Context, caller models.Caller, houseID string) (invitations []models.Invitation, err error)
+	Revoke(ctx context.Context, caller models.Caller, houseID string, invitationID string) error
+
+	Accept(ctx context.Context, caller models.Caller, invitationToken string) (houseID string, err error)
+	Decline(ctx context.Context, invitationToken string) error
+}
+
+// NewInvitationService returns the default invitation service.
+func NewInvitationService(invitationRepo repositories.InvitationRepository, houseRepo repositories.HouseRepository, userRepo repositories.UserRepository, mailer mailer.Mailer) InvitationService {
+	return &invitationService{
+		invitationRepo: invitationRepo,
+		houseRepo:      houseRepo,
+		userRepo:       userRepo,
+		mailer:         mailer,
+	}
+}
+
+type invitationService struct {
+	invitationRepo repositories.InvitationRepository
+	houseRepo      repositories.HouseRepository
+	userRepo       repositories.UserRepository
+	mailer         mailer.Mailer
+}
+
+// Invites someone to become a member of a house, by sending a link to his email address
+// He does not need an account yet, he can register with this email address then accept it
+// A new invitation to the same email address revokes the previous ones
+// Only the owner of the house or an admin can invite
+func (s *invitationService) Insert(ctx context.Context, caller models.Caller, houseID string, invitation models.Invitation) (insertedInvitationID string, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.Insert")
+	defer span.End()
+	house, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return "", err
+	}
+	inviter, found, err := s.userRepo.SelectBy(ctx, caller.UserID)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if !found {
+		return "", userGone
+	}
+	invitee, isRegistered, err := s.userRepo.SelectByEmail(ctx, invitation.Email)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if isRegistered && house.RoleOf(invitee.ID) != "" {
+		return "", errors.HouseMemberAlreadyExists
+	}
+
+	invitationToken, err := generateOpaqueToken()
+	if err != nil {
+		return "", internalError(err)
+	}
+	invitation.Email = strings.ToLower(invitation.Email)
+	err = s.invitationRepo.RevokePendingOf(ctx, houseID, invitation.Email)
+	if err != nil {
+		return "", internalError(err)
+	}
+	now := time.Now()
+	invitation.ID = ""
+	invitation.HouseID = houseID
+	invitation.InvitedBy = inviter.ID
+	invitation.TokenHash = hashOpaqueToken(invitationToken)
+	invitation.Status = models.InvitationPending
+	invitation.CreatedAt = now
+	invitation.ExpiresAt = now.Add(time.Hour * time.Duration(config.Current.InvitationExpirationTimeInHours))
+	insertedInvitationID, err = s.invitationRepo.Insert(ctx, invitation)
+	if err != nil {
+		return "", internalError(err)
+	}
+
+	// The invitation is stored even if the mail can not be sent, the owner can invite again
+	// Someone without account gets the mail in the language of the inviter
+	if !isRegistered {
+		invitee = models.User{Email: invitation.Email, Language: inviter.Language}
+	}
+	message, err := mailer.NewMessage(mailer.HouseInvitationTemplate, invitee, map[string]interface{}{
+		"Inviter":    inviter,
+		"House":      house,
+		"Role":       invitation.Role,
+		"Link":       config.Current.InvitationURL + invitationToken,
+		"ValidHours": config.Current.InvitationExpirationTimeInHours,
+	})
+	if err == nil {
+		err = s.mailer.Send(message)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("invitation mail not sent", "invitationID", insertedInvitationID, "error", err)
+	}
+	return insertedInvitationID, nil
+}
+
+// Returns the invitations of a house which have not been answered yet
+// Only the owner of the house or an admin can read them
+func (s *invitationService) GetPendingOfHouse(ctx context.Context, caller models.Caller, houseID string) (invitations []models.Invitation, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.GetPendingOfHouse")
+	defer span.End()
+	_, err = selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err = s.invitationRepo.SelectPendingOfHouse(ctx, houseID)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return invitations, nil
+}
+
+// Revokes a pending invitation, its link can not be used anymore
+// Only the owner of the house or an admin can revoke it
+func (s *invitationService) Revoke(ctx context.Context, caller models.Caller, houseID string, invitationID string) error {
+	ctx, span := tracing.Start(ctx, "InvitationService.Revoke")
+	defer span.End()
+	_, err := selectHouseWithRole(ctx, s.houseRepo, caller, houseID, models.HouseRoleOwner)
+	if err != nil {
+		return err
+	}
+	hasBeenRevoked, err := s.invitationRepo.UpdateStatus(ctx, houseID, invitationID, models.InvitationRevoked)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenRevoked {
+		return errors.ResourceNotFound
+	}
+	return nil
+}
+
+// Accepts an invitation, the caller becomes a member of its house with its role
+// The caller must be the user of the email address the invitation was sent to, the inviter is then notified by email
+func (s *invitationService) Accept(ctx context.Context, caller models.Caller, invitationToken string) (houseID string, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.Accept")
+	defer span.End()
+	invitation, err := s.selectPending(ctx, invitationToken)
+	if err != nil {
+		return "", err
+	}
+	user, found, err := s.userRepo.SelectBy(ctx, caller.UserID)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if !found {
+		return "", userGone
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return "", errors.InvitationEmailMismatch
+	}
+	house, found, err := s.houseRepo.SelectByID(ctx, invitation.HouseID)
+	if err != nil {
+		return "", internalError(err)
+	}
+	if !found { // deleted since
+		return "", errors.InvitationTokenInvalid
+	}
+	if house.RoleOf(user.ID) != "" {
+		return "", errors.HouseMemberAlreadyExists
+	}
+	if len(house.Members) >= maxMembersPerHouse {
+		return "", errors.HouseMembersLimitReached
+	}
+
+	// The member is added first, so that the invitation is not used if he can not be added,
+	// and removed if the invitation can not be accepted
+	err = insertHouseMember(ctx, s.houseRepo, house.ID, models.HouseMember{UserID: user.ID, Role: invitation.Role})
+	if err != nil {
+		return "", err
+	}
+	hasBeenAccepted, err := s.invitationRepo.UpdateStatus(ctx, invitation.HouseID, invitation.ID, models.InvitationAccepted)
+	if err != nil || !hasBeenAccepted {
+		if _, deleteErr := s.houseRepo.DeleteMember(ctx, house.ID, user.ID); deleteErr != nil {
+			logging.FromContext(ctx).Error("member of a failed invitation acceptance not removed", "invitationID", invitation.ID, "error", deleteErr)
+		}
+	}
+	if err != nil {
+		return "", internalError(err)
+	}
+	if !hasBeenAccepted { // answered or revoked meanwhile
+		return "", errors.InvitationTokenInvalid
+	}
+
+	// The member is added even if the inviter can not be notified
+	err = s.notifyAccepted(ctx, invitation, house, user)
+	if err != nil {
+		logging.FromContext(ctx).Error("invitation acceptance mail not sent", "invitationID", invitation.ID, "error", err)
+	}
+	return house.ID, nil
+}
+
+// Declines an invitation, its link can not be used anymore
+// No account is needed, the link is enough to decline
+func (s *invitationService) Decline(ctx context.Context, invitationToken string) error {
+	ctx, span := tracing.Start(ctx, "InvitationService.Decline")
+	defer span.End()
+	invitation, err := s.selectPending(ctx, invitationToken)
+	if err != nil {
+		return err
+	}
+	hasBeenDeclined, err := s.invitationRepo.UpdateStatus(ctx, invitation.HouseID, invitation.ID, models.InvitationDeclined)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenDeclined {
+		return errors.InvitationTokenInvalid
+	}
+	return nil
+}
+
+// Select the pending invitation of a token
+func (s *invitationService) selectPending(ctx context.Context, invitationToken string) (invitation models.Invitation, err error) {
+	if invitationToken == "" {
+		return models.Invitation{}, errors.RequiredFieldEmpty
+	}
+	invitation, found, err := s.invitationRepo.SelectPendingByTokenHash(ctx, hashOpaqueToken(invitationToken))
+	if err != nil {
+		return models.Invitation{}, internalError(err)
+	}
+	if !found {
+		return models.Invitation{}, errors.InvitationTokenInvalid
+	}
+	return invitation, nil
+}
+
+// Tells the inviter that his invitation has been accepted, in his language
+func (s *invitationService) notifyAccepted(ctx context.Context, invitation models.Invitation, house models.House, member models.User) error {
+	inviter, found, err := s.userRepo.SelectBy(ctx, invitation.InvitedBy)
+	if err != nil || !found {
+		return err
+	}
+	message, err := mailer.NewMessage(mailer.HouseInvitationAcceptedTemplate, inviter, map[string]interface{}{
+		"Member": member,
+		"House":  house,
+		"Role":   invitation.Role,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(message)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"goapi/config"
+	"goapi/errors"
+	"goapi/models"
+	"goapi/repositories"
+	"goapi/totp"
+	"goapi/tracing"
+	"strings"
+	"time"
+)
+
+type MFAService interface {
+	Enroll(ctx context.Context, caller models.Caller) (secret string, uri string, err error)
+	Confirm(ctx context.Context, caller models.Caller, code string) (recoveryCodes []string, err error)
+	Verify(ctx context.Context, mfaToken string, code string) (signedToken string, refreshToken string, err error)
+	Disable(ctx context.Context, caller models.Caller, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, caller models.Caller, code string) (recoveryCodes []string, err error)
+}
+
+// NewMFAService returns the default two-factor authentication service.
+// The clock gives the current time used to check the codes, it is time.Now except in tests
+func NewMFAService(userRepo repositories.UserRepository, loginAttemptRepo repositories.LoginAttemptRepository,
+	authService AuthService, clock func() time.Time) MFAService {
+	return &mfaService{
+		userRepo:         userRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		authService:      authService,
+		clock:            clock,
+	}
+}
+
+type mfaService struct {
+	userRepo         repositories.UserRepository
+	loginAttemptRepo repositories.LoginAttemptRepository
+	authService      AuthService
+	clock            func() time.Time
+}
+
+// Starts the two-factor authentication enrollment
+// A new secret is generated and returned with its otpauth URI, to be added in an authenticator application
+// It is only enabled once a first code is sent to Confirm
+func (s *mfaService) Enroll(ctx context.Context, caller models.Caller) (secret string, uri string, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Enroll")
+	defer span.End()
+	user, err := s.selectUser(ctx, caller.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", errors.MFAAlreadyEnabled
+	}
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	_, err = s.userRepo.UpdateTOTP(ctx, user.ID, secret, false, nil)
+	if err != nil {
+		return "", "", updateError(err)
+	}
+	return secret, totp.URI(config.Current.TOTPIssuer, user.Email, secret), nil
+}
+
+// Confirms the enrollment with a first code of the authenticator application and enables two-factor authentication
+// Recovery codes are returned in clear, this is the only time they can be read
+func (s *mfaService) Confirm(ctx context.Context, caller models.Caller, code string) (recoveryCodes []string, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Confirm")
+	defer span.End()
+	user, err := s.selectUser(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.MFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.MFANotEnrolled
+	}
+	err = s.checkCode(ctx, user, code, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user)
+}
+
+// Second step of the login for the users with two-factor authentication
+// Checks the code, or a recovery code, of the user of the MFA pending token sent by AuthService.Login
+// and sends a new JWT and refresh token
+func (s *mfaService) Verify(ctx context.Context, mfaToken string, code string) (signedToken string, refreshToken string, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.Verify")
+	defer span.End()
+	userID, err := s.authService.ParseMFAPendingToken(mfaToken)
+	if err != nil {
+		return "", "", err
+	}
+	user, found, err := s.userRepo.SelectBy(ctx, userID)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	if !found || !user.TOTPEnabled {
+		return "", "", errors.MFATokenInvalid
+	}
+	err = s.checkCode(ctx, user, code, true)
+	if err != nil {
+		return "", "", err
+	}
+	signedToken, refreshToken, err = s.authService.GenerateTokens(ctx, user.ID, user.Roles)
+	if err != nil {
+		return "", "", internalError(err)
+	}
+	return signedToken, refreshToken, nil
+}
+
+// Disables two-factor authentication, a code or a recovery code is required
+func (s *mfaService) Disable(ctx context.Context, caller models.Caller, code string) error {
+	ctx, span := tracing.Start(ctx, "MFAService.Disable")
+	defer span.End()
+	user, err := s.selectUser(ctx, caller.UserID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errors.MFANotEnabled
+	}
+	err = s.checkCode(ctx, user, code, true)
+	if err != nil {
+		return err
+	}
+	_, err = s.userRepo.UpdateTOTP(ctx, user.ID, "", false, nil)
+	if err != nil {
+		return updateError(err)
+	}
+	return nil
+}
+
+// Replaces the recovery codes of the user, a code of the authenticator application is required
+// The new recovery codes are returned in clear, this is the only time they can be read
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, caller models.Caller, code string) (recoveryCodes []string, err error) {
+	ctx, span := tracing.Start(ctx, "MFAService.RegenerateRecoveryCodes")
+	defer span.End()
+	user, err := s.selectUser(ctx, caller.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.MFANotEnabled
+	}
+	err = s.checkCode(ctx, user, code, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, user)
+}
+
+// Selects the user of the caller
+func (s *mfaService) selectUser(ctx context.Context, userID string) (models.User, error) {
+	user, found, err := s.userRepo.SelectBy(ctx, userID)
+	if err != nil {
+		return models.User{}, internalError(err)
+	}
+	if !found {
+		return models.User{}, errors.ResourceNotFound
+	}
+	return user, nil
+}
+
+// Generates new recovery codes, enables two-factor authentication with them and returns them in clear
+func (s *mfaService) replaceRecoveryCodes(ctx context.Context, user models.User) ([]string, error) {
+	recoveryCodes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, internalError(err)
+	}
+	_, err = s.userRepo.UpdateTOTP(ctx, user.ID, user.TOTPSecret, true, hashes)
+	if err != nil {
+		return nil, updateError(err)
+	}
+	return recoveryCodes, nil
+}
+
+// Checks a code of the user, and consumes it so it can not be used again
+// Recovery codes are only accepted if allowRecoveryCode is true
+// Failures are counted like the login ones, to prevent brute-forcing the codes
+func (s *mfaService) checkCode(ctx context.Context, user models.User, code string, allowRecoveryCode bool) error {
+	key := "mfa:" + user.ID
+	failures, err := reserveLoginAttempt(ctx, s.loginAttemptRepo, key, accountLoginLimits(), s.clock())
+	if err != nil {
+		return err
+	}
+
+	consumed := false
+	if valid, step := totp.Validate(user.TOTPSecret, code, s.clock(), int64(config.Current.TOTPSkewSteps)); valid {
+		consumed, err = s.userRepo.ConsumeTOTPStep(ctx, user.ID, step)
+	} else if allowRecoveryCode {
+		consumed, err = s.userRepo.ConsumeRecoveryCode(ctx, user.ID, hashOpaqueToken(normalizeRecoveryCode(code)))
+	}
+	if err != nil {
+		return internalError(err)
+	}
+	if !consumed {
+		err = lockIfTooManyFailures(ctx, s.loginAttemptRepo, key, failures, accountLoginLimits(), s.clock())
+		if err != nil {
+			return internalError(err)
+		}
+		return errors.MFACodeInvalid
+	}
+	err = s.loginAttemptRepo.DeleteByKey(ctx, key)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Generates the recovery codes, formatted as XXXXX-XXXXX, and their hashes to store
+func generateRecoveryCodes() (recoveryCodes []string, hashes []string, err error) {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // without the ambiguous I, O, 0 and 1
+	for i := 0; i < config.Current.RecoveryCodesCount; i++ {
+		b, err := generateSalt(10)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := make([]byte, len(b))
+		for j := range b {
+			code[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+		recoveryCode := string(code[:5]) + "-" + string(code[5:])
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		hashes = append(hashes, hashOpaqueToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	return recoveryCodes, hashes, nil
+}
+
+// Recovery codes are accepted whatever their case and separators
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -1,49 +1,58 @@
 package services
 
 import (
-	"errors"
-	"github.com/gofiber/fiber"
-	"goapi/errors/errorCodes"
-	"goapi/errors/errorDesc"
+	"context"
+	"goapi/errors"
+	"goapi/logging"
 	"goapi/models"
 	"goapi/repositories"
+	"goapi/tracing"
 )
 
 type UserService interface {
-	Insert(models.User) (statusCode int, insertedUserID string, err error, errorCode string)
-	GetAll(limit int) (users []models.User, err error)
-	GetByID(id string) (user models.User, err error)
-	UpdateByID(id string, userUpdates models.User) (statusCode int, hasBeenUpdated bool, err error, errorCode string)
-	DeleteByID(id string) (hasBeenDeleted bool, err error)
+	Insert(ctx context.Context, user models.User) (insertedUserID string, err error)
+	GetAll(ctx context.Context, query models.ListQuery) (users []models.User, page models.Page, err error)
+	GetByID(ctx context.Context, caller models.Caller, id string) (user models.User, err error)
+	UpdateByID(ctx context.Context, caller models.Caller, id string, userUpdates models.User) error
+	DeleteByID(ctx context.Context, caller models.Caller, id string) error
+
+	SetEnabled(ctx context.Context, caller models.Caller, id string, enabled bool) error
+	SetRoles(ctx context.Context, caller models.Caller, id string, roles []string) error
 }
 
 // NewUserService returns the default user service.
-func NewUserService(repo repositories.UserRepository) UserService {
+// The auth service is used to revoke the sessions of an user when needed
+func NewUserService(repo repositories.UserRepository, authService AuthService) UserService {
 	return &userService{
-		repo: repo,
+		repo:        repo,
+		authService: authService,
 	}
 }
 
 type userService struct {
-	repo repositories.UserRepository
+	repo        repositories.UserRepository
+	authService AuthService
 }
 
 // Insert an user
 // This will check if the email domain provided is ok, the email address is not already taken
 // Password is hashed and salted with the security methods in the AuthService
-func (s *userService) Insert(user models.User) (statusCode int, insertedUserID string, err error, errorCode string) {
+// A verification link is sent to the email address
+func (s *userService) Insert(ctx context.Context, user models.User) (insertedUserID string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Insert")
+	defer span.End()
 	// Checks if email domain is good
 	if !validEmailAddress(user.Email) {
-		return fiber.StatusNotAcceptable, "failed", errors.New(errorDesc.EmailAddressDomainForbidden), errorCodes.EmailAddressDomainForbidden
+		return "", errors.EmailAddressDomainForbidden
 	}
 
 	// Checks if the email address given by the user already exists our database
-	emailAddressAlreadyTaken, err := s.repo.EmailAddressExists(user.Email)
+	emailAddressAlreadyTaken, err := s.repo.EmailAddressExists(ctx, user.Email)
 	if err != nil {
-		return fiber.StatusInternalServerError, "failed", err, errorCodes.InternalServerError
+		return "", internalError(err)
 	}
 	if emailAddressAlreadyTaken {
-		return fiber.StatusConflict, "failed", errors.New(errorDesc.EmailAddressAlreadyExists), errorCodes.EmailAddressAlreadyExists
+		return "", errors.EmailAddressAlreadyExists
 	}
 	salt, _ := generateSalt(32) // salt is []byte
 
@@ -52,45 +61,79 @@ func (s *userService) Insert(user models.User) (statusCode int, insertedUserID s
 	user.Password = hashAndSalt([]byte(user.Password), salt)
 	user.Verified = false
 	user.Enabled = true
+	user.Roles = models.DefaultRoles
+	user.TOTPEnabled = false
+
+	insertedUserID, err = s.repo.Insert(ctx, user)
+	if err != nil {
+		return "", internalError(err)
+	}
 
-	insertedUserID, err = s.repo.Insert(user)
+	// The user is registered even if the mail can not be sent, he can ask for a new one
+	user.ID = insertedUserID
+	err = s.authService.SendEmailVerification(ctx, user)
 	if err != nil {
-		return fiber.StatusInternalServerError, errorDesc.Unknown, err, errorCodes.Unknown
+		logging.FromContext(ctx).Error("email verification mail not sent", "userID", insertedUserID, "error", err)
 	}
-	return fiber.StatusOK, insertedUserID, nil, ""
+	return insertedUserID, nil
 }
 
-// Returns all users
-// Use the limit parameter to limit the number of returned users
-func (s *userService) GetAll(limit int) (users []models.User, err error) {
-	return s.repo.SelectMany(limit)
+// Returns a page of all the users
+func (s *userService) GetAll(ctx context.Context, query models.ListQuery) (users []models.User, page models.Page, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAll")
+	defer span.End()
+	users, page, err = s.repo.SelectPage(ctx, query)
+	if err != nil {
+		return nil, models.Page{}, listError(err)
+	}
+	return users, page, nil
 }
 
 // Returns an user by its id
-func (s *userService) GetByID(id string) (user models.User, err error) {
-	user, found := s.repo.SelectBy(id)
+// Only the user himself or an admin can read it
+func (s *userService) GetByID(ctx context.Context, caller models.Caller, id string) (user models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetByID")
+	defer span.End()
+	if !caller.CanAccess(id) {
+		return models.User{}, errors.Forbidden
+	}
+	user, found, err := s.repo.SelectBy(ctx, id)
+	if err != nil {
+		return models.User{}, internalError(err)
+	}
 	if !found {
-		return user, errors.New(errorDesc.ResourceNotFound)
+		return models.User{}, errors.ResourceNotFound
 	}
 	return user, nil
 }
 
 // Update an user by its id
 // If the email is requested to be updated, it will first check if the domain is valid
-// and if it does not already exists
-func (s *userService) UpdateByID(id string, user models.User) (statusCode int, hasBeenUpdated bool, err error, errorCode string) {
+// and if it does not already exists, then a verification link is sent to the new address
+// Only the user himself or an admin can update it
+// Roles, status and two-factor authentication can not be updated here, see SetRoles, SetEnabled and MFAService
+func (s *userService) UpdateByID(ctx context.Context, caller models.Caller, id string, user models.User) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateByID")
+	defer span.End()
+	if !caller.CanAccess(id) {
+		return errors.Forbidden
+	}
+	user.Roles = nil
+	user.Enabled = false     // false is omitted when updating
+	user.Verified = false    // only set by the email verification
+	user.TOTPEnabled = false // only set by the MFA service
 
 	// Checks if the email address given by the user already exists in the database
 	if user.Email != "" {
 		if !validEmailAddress(user.Email) {
-			return fiber.StatusNotAcceptable, false, errors.New(errorDesc.EmailAddressDomainForbidden), errorCodes.EmailAddressDomainForbidden
+			return errors.EmailAddressDomainForbidden
 		}
-		emailAddressAlreadyTaken, err := s.repo.EmailAddressExists(user.Email)
+		emailAddressAlreadyTaken, err := s.repo.EmailAddressExists(ctx, user.Email)
 		if err != nil {
-			return fiber.StatusInternalServerError, false, err, errorCodes.InternalServerError
+			return internalError(err)
 		}
 		if emailAddressAlreadyTaken {
-			return fiber.StatusConflict, false, errors.New(errorDesc.EmailAddressAlreadyExists), errorCodes.EmailAddressAlreadyExists
+			return errors.EmailAddressAlreadyExists
 		}
 	}
 	// Check for password update and hash the new password if needed
@@ -100,18 +143,104 @@ func (s *userService) UpdateByID(id string, user models.User) (statusCode int, h
 		user.Password = hashAndSalt([]byte(user.Password), salt)
 	}
 
-	hasBeenUpdated, err = s.repo.Update(id, user)
+	_, err := s.repo.Update(ctx, id, user)
 	if err != nil {
-		return fiber.StatusNotFound, hasBeenUpdated, err, errorCodes.ResourceNotFound
+		return updateError(err)
 	}
-	return fiber.StatusOK, hasBeenUpdated, nil, ""
+	// A new email address must be verified again
+	if user.Email != "" {
+		_, err = s.repo.UpdateVerified(ctx, id, false)
+		if err != nil {
+			return updateError(err)
+		}
+		updatedUser, _, err := s.repo.SelectBy(ctx, id)
+		if err != nil {
+			return internalError(err)
+		}
+		err = s.authService.SendEmailVerification(ctx, updatedUser)
+		if err != nil {
+			logging.FromContext(ctx).Error("email verification mail not sent", "userID", id, "error", err)
+		}
+	}
+	// A new password kills the existing sessions
+	if user.Password != "" {
+		err = s.authService.RevokeAllSessions(ctx, id)
+		if err != nil {
+			return internalError(err)
+		}
+	}
+	return nil
 }
 
-// Tells the UserRepository to delete an user by its id
-func (s *userService) DeleteByID(id string) (hasBeenDeleted bool, err error) {
-	hasBeenDeleted, err = s.repo.DeleteBy(id)
-	if err != nil || !hasBeenDeleted {
-		return false, err
+// Tells the UserRepository to delete an user by its id, its sessions are revoked
+// Only the user himself or an admin can delete it
+func (s *userService) DeleteByID(ctx context.Context, caller models.Caller, id string) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteByID")
+	defer span.End()
+	if !caller.CanAccess(id) {
+		return errors.Forbidden
+	}
+	hasBeenDeleted, err := s.repo.DeleteBy(ctx, id)
+	if err != nil {
+		return internalError(err)
+	}
+	if !hasBeenDeleted {
+		return errors.ResourceNotFound
+	}
+	err = s.authService.RevokeAllSessions(ctx, id)
+	if err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// Enables or disables an user
+// A disabled user can not login or refresh its JWT anymore, and its sessions are revoked
+// Only an admin can do it
+func (s *userService) SetEnabled(ctx context.Context, caller models.Caller, id string, enabled bool) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetEnabled")
+	defer span.End()
+	if !caller.IsAdmin() {
+		return errors.Forbidden
+	}
+	_, err := s.repo.UpdateEnabled(ctx, id, enabled)
+	if err != nil {
+		return updateError(err)
+	}
+	if !enabled {
+		err = s.authService.RevokeAllSessions(ctx, id)
+		if err != nil {
+			return internalError(err)
+		}
+	}
+	return nil
+}
+
+// Replaces the roles of an user
+// Roles can be the built-in ones or custom ones, at least one role must be given
+// Its sessions are revoked, as their JWTs carry the previous roles
+// Only an admin can do it
+func (s *userService) SetRoles(ctx context.Context, caller models.Caller, id string, roles []string) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetRoles")
+	defer span.End()
+	if !caller.IsAdmin() {
+		return errors.Forbidden
+	}
+	if len(roles) == 0 {
+		return errors.RequiredFieldEmpty
+	}
+	for _, role := range roles {
+		if role == "" {
+			return errors.RequiredFieldEmpty
+		}
+	}
+	_, err := s.repo.UpdateRoles(ctx, id, roles)
+	if err != nil {
+		return updateError(err)
+	}
+	err = s.authService.RevokeAllSessions(ctx, id)
+	if err != nil {
+		return internalError(err)
 	}
-	return true, nil
+	return nil
 }
@@ -0,0 +1,84 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK is a public key in the JSON Web Key format of RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (RFC 8037)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is the set of keys published on /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public verification keys, so that other services can verify the tokens
+// The signing key comes first, then the other ones sorted by kid
+// HMAC keys are secrets and never published
+func (ks *KeySet) JWKS() JWKS {
+	keys := []*Key{ks.signingKey}
+	for _, key := range ks.keys {
+		if key != ks.signingKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys[1:], func(i, j int) bool { return keys[i+1].ID < keys[j+1].ID })
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range keys {
+		jwk := toJWK(key)
+		if jwk.KeyType == "" {
+			continue
+		}
+		jwk.Use = "sig"
+		jwk.Algorithm = key.Method.Alg()
+		jwk.KeyID = key.ID
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// Returns the JWK of the public key, with only its required members
+// The key type is empty for HMAC keys
+func toJWK(key *Key) JWK {
+	switch k := key.publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType: "RSA",
+			N:       base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Curve: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}
+	}
+	return JWK{}
+}
+
+// RFC 7638 thumbprint of a JWK: the hash of its required members in lexicographic order
+func thumbprint(jwk JWK) string {
+	var canonical string
+	switch jwk.KeyType {
+	case "RSA":
+		canonical = `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	case "OKP":
+		canonical = `{"crv":"` + jwk.Curve + `","kty":"OKP","x":"` + jwk.X + `"}`
+	}
+	hash := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,144 @@
+// Package signing manages the keys used to sign and verify the JWTs
+// Tokens are signed with a single key, but several keys can be accepted for verification
+// so that the signing key can be rotated without invalidating the tokens signed with the previous one
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+)
+
+// Key is a key identified by its kid header
+// The private key is only set for the signing key
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	privateKey interface{}
+	publicKey  interface{} // for HMAC, the secret itself
+}
+
+// KeySet holds the signing key and every key accepted for verification, by kid
+type KeySet struct {
+	signingKey *Key
+	keys       map[string]*Key
+}
+
+// NewHMACKeySet returns a key set signing and verifying with a shared secret, using HS512
+// Every service verifying the tokens needs the secret, so no key is published in the JWKS
+func NewHMACKeySet(secret []byte) *KeySet {
+	hash := sha256.Sum256(secret)
+	key := &Key{
+		ID:         "hs512-" + base64.RawURLEncoding.EncodeToString(hash[:6]),
+		Method:     jwt.SigningMethodHS512,
+		privateKey: secret,
+		publicKey:  secret,
+	}
+	return &KeySet{signingKey: key, keys: map[string]*Key{key.ID: key}}
+}
+
+// LoadKeySet loads the PEM encoded signing private key, RSA (RS256) or Ed25519 (EdDSA),
+// and the previous keys still accepted for verification, either public or private PEM keys
+// The kid of each key is its RFC 7638 thumbprint, so it does not change when the key is reloaded
+func LoadKeySet(signingKeyFile string, verificationKeyFiles []string) (*KeySet, error) {
+	signingKey, err := loadKeyFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if signingKey.privateKey == nil {
+		return nil, fmt.Errorf("%s: the signing key must be a private key", signingKeyFile)
+	}
+	keySet := &KeySet{signingKey: signingKey, keys: map[string]*Key{signingKey.ID: signingKey}}
+	for _, file := range verificationKeyFiles {
+		key, err := loadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key.privateKey = nil // only used for verification
+		keySet.keys[key.ID] = key
+	}
+	return keySet, nil
+}
+
+// NewToken returns a new token signed with the signing key once passed to Sign
+// Its kid header tells which key verifies it
+func (ks *KeySet) NewToken(claims jwt.Claims) *jwt.Token {
+	token := jwt.NewWithClaims(ks.signingKey.Method, claims)
+	token.Header["kid"] = ks.signingKey.ID
+	return token
+}
+
+// Sign signs a token built by NewToken
+func (ks *KeySet) Sign(token *jwt.Token) (string, error) {
+	return token.SignedString(ks.signingKey.privateKey)
+}
+
+// Keyfunc returns the verification key of a token, from its kid header
+// Tokens without kid or with an algorithm other than the one of their key are refused
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown jwt key id %v", token.Header["kid"])
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method %v", token.Header["alg"])
+	}
+	return key.publicKey, nil
+}
+
+// SigningMethod returns the method of the signing key
+func (ks *KeySet) SigningMethod() jwt.SigningMethod {
+	return ks.signingKey.Method
+}
+
+// Loads a PEM key file, private or public
+func loadKeyFile(file string) (*Key, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key found", file)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %s", file, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.privateKey, key.publicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.publicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.privateKey, key.publicKey = SigningMethodEdDSA, k, k.Public().(ed25519.PublicKey)
+	case ed25519.PublicKey:
+		key.Method, key.publicKey = SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", file)
+	}
+	key.ID = thumbprint(toJWK(key))
+	return key, nil
+}
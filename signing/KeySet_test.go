@@ -0,0 +1,107 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Writes a PKCS8 PEM private key in dir and returns its path
+func writeKeyFile(t *testing.T, dir string, name string, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, name)
+	err = ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaFile := writeKeyFile(t, dir, "rsa.pem", rsaKey)
+	edFile := writeKeyFile(t, dir, "ed25519.pem", edKey)
+	otherFile := writeKeyFile(t, dir, "other.pem", otherKey)
+
+	previous, err := LoadKeySet(rsaFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := LoadKeySet(edFile, []string{rsaFile}) // the RSA key is rotated out but still verifies
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadKeySet(otherFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmac := NewHMACKeySet([]byte("secret"))
+
+	// A token signed with HS256 and the kid of the RSA key, by someone using its public key as secret
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "u1"})
+	confused.Header["kid"] = previous.signingKey.ID
+	publicDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	confusedToken, _ := confused.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+
+	withoutKid := jwt.NewWithClaims(SigningMethodEdDSA, jwt.MapClaims{"sub": "u1"})
+	withoutKidToken, _ := withoutKid.SignedString(edKey)
+
+	tests := []struct {
+		name   string
+		signer *KeySet
+		token  string
+		valid  bool
+	}{
+		{"current key", current, "", true},
+		{"previous key", previous, "", true},
+		{"unknown key", other, "", false},
+		{"hmac key", hmac, "", false},
+		{"algorithm confusion", nil, confusedToken, false},
+		{"without kid", nil, withoutKidToken, false},
+	}
+	for _, test := range tests {
+		signed := test.token
+		if test.signer != nil {
+			signed, err = test.signer.Sign(test.signer.NewToken(jwt.MapClaims{"sub": "u1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		token, err := jwt.Parse(signed, current.Keyfunc)
+		valid := err == nil && token.Valid
+		if valid != test.valid {
+			t.Errorf("%s: valid = %v, want %v (%v)", test.name, valid, test.valid, err)
+		}
+	}
+
+	// Only the public keys are published, the HMAC secret never is
+	if keys := current.JWKS().Keys; len(keys) != 2 {
+		t.Errorf("JWKS has %d keys, want 2", len(keys))
+	}
+	if keys := hmac.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS of an HMAC key set has %d keys, want 0", len(keys))
+	}
+}
@@ -0,0 +1,102 @@
+// Package totp implements the time-based one-time passwords of RFC 6238
+// with the default parameters used by authenticator applications: HMAC-SHA1, 30 seconds steps and 6 digits
+// Every function takes the time as a parameter so that it can be used with a fixed clock
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Step is the duration during which a code is valid
+const Step = 30 * time.Second
+
+// Digits is the number of digits of a code
+const Digits = 6
+
+// SecretSize is the size of the generated secrets in bytes, 160 bits as recommended by RFC 4226
+const SecretSize = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret, encoded in base32 as expected by authenticator applications
+func GenerateSecret() (string, error) {
+	b := make([]byte, SecretSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// StepAt returns the number of the step containing t (T in RFC 6238)
+func StepAt(t time.Time) int64 {
+	return t.Unix() / int64(Step/time.Second)
+}
+
+// CodeAt returns the code of a base32 secret for the step containing t
+func CodeAt(secret string, t time.Time) (string, error) {
+	return codeForStep(secret, StepAt(t))
+}
+
+// Validate checks a code at the time t, accepting the codes of the steps in [-skew, +skew] around t
+// to tolerate clock drifts between the server and the user device
+// The matching step is returned, it must be stored by the caller to refuse this code if it is used again
+func Validate(secret string, code string, t time.Time, skew int64) (valid bool, step int64) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return false, 0
+	}
+	current := StepAt(t)
+	for s := current - skew; s <= current+skew; s++ {
+		expected, err := codeForStep(secret, s)
+		if err != nil {
+			return false, 0
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return true, s
+		}
+	}
+	return false, 0
+}
+
+// URI returns the otpauth URI of a secret, usually shown as a QR code to be scanned by an authenticator application
+func URI(issuer string, accountName string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprintf("%d", Digits))
+	values.Set("period", fmt.Sprintf("%d", int64(Step/time.Second)))
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// HOTP value of RFC 4226 for the counter, truncated to the number of digits
+func codeForStep(secret string, counter int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%modulo), nil
+}
@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// The SHA1 secret of RFC 6238 Appendix B, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA1 test vectors of RFC 6238 Appendix B, with the last 6 of their 8 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeAt(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := CodeAt(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("CodeAt(%d): %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("CodeAt(%d) = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	clock := time.Unix(1111111111, 0) // step 37037037
+	tests := []struct {
+		name      string
+		code      string
+		skew      int64
+		wantValid bool
+		wantStep  int64
+	}{
+		{"current step", "050471", 0, true, 37037037},
+		{"spaces around", " 050471 ", 0, true, 37037037},
+		{"previous step without skew", "081804", 0, false, 0},
+		{"previous step with skew", "081804", 1, true, 37037036},
+		{"wrong code", "123456", 1, false, 0},
+		{"too short", "05047", 1, false, 0},
+		{"too long", "0050471", 1, false, 0},
+	}
+	for _, test := range tests {
+		valid, step := Validate(rfcSecret, test.code, clock, test.skew)
+		if valid != test.wantValid || step != test.wantStep {
+			t.Errorf("%s: Validate = %v, %d, want %v, %d", test.name, valid, step, test.wantValid, test.wantStep)
+		}
+	}
+}
+
+func TestValidateGeneratedSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Unix(1600000000, 0)
+	code, err := CodeAt(secret, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid, step := Validate(secret, code, clock.Add(Step), 1); !valid || step != StepAt(clock) {
+		t.Errorf("Validate one step later = %v, %d, want true, %d", valid, step, StepAt(clock))
+	}
+	if valid, _ := Validate(secret, code, clock.Add(2*Step), 1); valid {
+		t.Error("Validate two steps later = true, want false")
+	}
+}
@@ -0,0 +1,162 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span across services, it is what the traceparent header carries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool // the span is recorded and exported
+}
+
+// IsValid tells if the span context has non-zero IDs
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+type SpanKind int
+
+// Same values as the OTLP span kinds
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// SpanData is a finished span, as given to the exporters
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID // zero for a root span
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{} // string, bool, int, int64 or float64 values
+	Error         bool
+	StatusMessage string
+}
+
+// Span is an operation being traced, created by Start and ended by End
+// Spans which are not sampled or without tracer are not recorded, but still carry the context to propagate
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// Context returns the span context, to propagate it
+func (s *Span) Context() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetName replaces the name of the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute sets an attribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]interface{}{}
+	}
+	s.data.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError sets the status of the span to error, nil errors are ignored
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = true
+	s.data.StatusMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends the span and queues it for the export, if it is recorded
+// Calls after the first one do nothing
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer != nil && data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a context carrying the span, the spans started from it are its children
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span of the context, nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts an internal span, child of the span of the context, with the current tracer
+// The returned context carries the new span
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	var parent SpanContext
+	if span := SpanFromContext(ctx); span != nil {
+		parent = span.Context()
+	}
+	return StartWithParent(ctx, parent, name, SpanKindInternal)
+}
+
+// StartWithParent starts a span with an explicit parent, e.g. extracted from a traceparent header
+// An invalid parent starts a new trace, which is sampled
+func StartWithParent(ctx context.Context, parent SpanContext, name string, kind SpanKind) (context.Context, *Span) {
+	spanContext := SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+	if !parent.IsValid() {
+		spanContext = SpanContext{TraceID: newTraceID(), SpanID: spanContext.SpanID, Sampled: true}
+	}
+	span := &Span{
+		tracer: currentTracer(),
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  spanContext,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+		},
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
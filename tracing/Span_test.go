@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+// Sets a tracer exporting in memory for the test, Shutdown exports the queued spans
+func newTestTracer(t *testing.T) (*Tracer, *InMemoryExporter) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter, 16)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tracer, exporter
+}
+
+func TestSpanParent(t *testing.T) {
+	tracer, exporter := newTestTracer(t)
+	remote := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true}
+
+	ctx, server := StartWithParent(context.Background(), remote, "GET /houses", SpanKindServer)
+	_, child := Start(ctx, "HouseRepository.List")
+	child.End()
+	child.End() // ended once only
+	server.End()
+	_, root := StartWithParent(context.Background(), SpanContext{}, "job", SpanKindInternal)
+	root.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans, want 3", len(spans))
+	}
+	childData, serverData, rootData := spans[0], spans[1], spans[2]
+	if serverData.SpanContext.TraceID != remote.TraceID || serverData.ParentSpanID != remote.SpanID || serverData.Kind != SpanKindServer {
+		t.Errorf("server span = %+v, want trace %s and parent %s", serverData, remote.TraceID, remote.SpanID)
+	}
+	if childData.SpanContext.TraceID != remote.TraceID || childData.ParentSpanID != serverData.SpanContext.SpanID || childData.Kind != SpanKindInternal {
+		t.Errorf("child span = %+v, want trace %s and parent %s", childData, remote.TraceID, serverData.SpanContext.SpanID)
+	}
+	if rootData.SpanContext.TraceID == remote.TraceID || rootData.ParentSpanID != (SpanID{}) || !rootData.SpanContext.IsValid() {
+		t.Errorf("root span = %+v, want a new trace without parent", rootData)
+	}
+	if childData.End.Before(childData.Start) {
+		t.Errorf("child span ends at %v, before its start %v", childData.End, childData.Start)
+	}
+}
+
+func TestUnsampledSpan(t *testing.T) {
+	tracer, exporter := newTestTracer(t)
+	remote := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: false}
+
+	ctx, server := StartWithParent(context.Background(), remote, "GET /houses", SpanKindServer)
+	_, child := Start(ctx, "HouseRepository.List")
+	child.End()
+	server.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not recorded, but the context is still propagated
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Errorf("exported %d spans, want 0", len(spans))
+	}
+	if sc := child.Context(); sc.TraceID != remote.TraceID || sc.Sampled {
+		t.Errorf("child context = %+v, want trace %s not sampled", sc, remote.TraceID)
+	}
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"goapi/logging"
+	"sync"
+	"time"
+)
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+}
+
+const maxBatchSize = 512
+const exportInterval = 5 * time.Second
+
+// NewTracer returns a tracer which exports the spans in background by batches
+// Shutdown must be called on shutdown so that the queued spans are exported
+func NewTracer(exporter Exporter, queueSize int) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		queue:    make(chan SpanData, queueSize),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+type Tracer struct {
+	exporter Exporter
+	queue    chan SpanData
+	done     chan struct{} // closed when every queued span is exported
+	mu       sync.RWMutex
+	closed   bool
+}
+
+var tracerMu sync.RWMutex
+var tracer *Tracer
+
+// SetTracer sets the tracer of the spans started from now, nil stops recording spans
+func SetTracer(t *Tracer) {
+	tracerMu.Lock()
+	tracer = t
+	tracerMu.Unlock()
+}
+
+func currentTracer() *Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return tracer
+}
+
+// Queues a span, it is dropped if the queue is full so that requests never wait for the backend
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+	}
+}
+
+// Shutdown stops accepting spans and waits until the queued ones are exported, or until the context is done
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Exports the queued spans when the batch is full, on every interval and when the queue is closed
+func (t *Tracer) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportInterval)
+		if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+			logging.Error("trace export failed", "spans", len(batch), "error", err)
+		}
+		cancel()
+		batch = nil
+	}
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				export()
+				close(t.done)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= maxBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+// InMemoryExporter keeps the exported spans in memory, for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpans(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans, call Shutdown on the tracer before so that none is still queued
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData{}, e.spans...)
+}
+
+// Reset forgets the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
@@ -0,0 +1,215 @@
+package validation
+
+import (
+	"fmt"
+	"goapi/errors"
+	"goapi/errors/errorCodes"
+	"goapi/errors/errorDesc"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Rules are set with the validate tag of the model fields, separated by commas, e.g. `validate:"required,max=50"`
+// - required: the field must not be empty
+// - min=N, max=N: length of a string, number of items of a slice, or value of a number
+// - email: the string must be an email address
+// - oneof=A B C: the value must be one of the space separated values
+// Rules other than required only apply to the fields which are not empty
+// Nested structs, and structs in slices, are validated too, their fields are named after their path, e.g. rooms[0].name
+const tagName = "validate"
+
+// Validate checks a request body against the validate tags of its fields
+// All the invalid fields are returned at once, in the details of a validation error, nil if the body is valid
+func Validate(body interface{}) error {
+	return validate(body, false)
+}
+
+// ValidateUpdate is Validate for partial updates: empty fields are not updated, so they are not required
+// The nested structs must still be complete, e.g. the rooms of a house
+func ValidateUpdate(body interface{}) error {
+	return validate(body, true)
+}
+
+func validate(body interface{}, partial bool) error {
+	var details []errors.FieldError
+	validateValue(reflect.ValueOf(body), "", partial, &details)
+	if len(details) > 0 {
+		return errors.ValidationFailed.WithDetails(details...)
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, partial bool, details *[]errors.FieldError) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			fv := v.Field(f.index)
+			name := path + f.name
+			if isEmpty(fv) {
+				if f.required && !partial {
+					*details = append(*details, errors.FieldError{Field: name, Code: errorCodes.RequiredFieldEmpty, Description: errorDesc.FieldRequired})
+				}
+				continue
+			}
+			for _, r := range f.rules {
+				if detail, ok := r.check(indirect(fv)); !ok {
+					detail.Field = name
+					*details = append(*details, detail)
+				}
+			}
+			validateValue(fv, name+".", false, details)
+		}
+	case reflect.Slice, reflect.Array:
+		parent := strings.TrimSuffix(path, ".")
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), parent+"["+strconv.Itoa(i)+"].", false, details)
+		}
+	}
+}
+
+// A struct field with its JSON name and its rules
+type field struct {
+	index    int
+	name     string
+	required bool
+	rules    []rule
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+// Fields by struct type, the tags are only parsed once
+var fieldsCache sync.Map
+
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		jsonName := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.PkgPath != "" || jsonName == "-" { // unexported or never received
+			continue
+		}
+		if jsonName == "" {
+			jsonName = sf.Name
+		}
+		f := field{index: i, name: jsonName}
+		for _, part := range strings.Split(sf.Tag.Get(tagName), ",") {
+			if part == "" {
+				continue
+			}
+			nameAndParam := strings.SplitN(part, "=", 2)
+			r := rule{name: nameAndParam[0]}
+			if len(nameAndParam) == 2 {
+				r.param = nameAndParam[1]
+			}
+			switch r.name {
+			case "required":
+				f.required = true
+			case "min", "max":
+				if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+					panic(fmt.Sprintf("validation: %s.%s: %s needs a number", t.Name(), sf.Name, r.name))
+				}
+				f.rules = append(f.rules, r)
+			case "email", "oneof":
+				f.rules = append(f.rules, r)
+			default:
+				panic(fmt.Sprintf("validation: %s.%s: unknown rule %q", t.Name(), sf.Name, r.name))
+			}
+		}
+		fields = append(fields, f)
+	}
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+// Checks a value which is not empty, returns the detail of the error without its field if it is not valid
+func (r rule) check(v reflect.Value) (errors.FieldError, bool) {
+	switch r.name {
+	case "min", "max":
+		limit, _ := strconv.ParseFloat(r.param, 64)
+		size, kind := sizeOf(v)
+		if (r.name == "min" && size < limit) || (r.name == "max" && size > limit) {
+			detail := rangeErrors[r.name][kind]
+			detail.Description = fmt.Sprintf(detail.Description, r.param)
+			return detail, false
+		}
+	case "email":
+		address, err := mail.ParseAddress(v.String())
+		if err != nil || address.Address != v.String() || !strings.Contains(address.Address[strings.LastIndex(address.Address, "@"):], ".") {
+			return errors.FieldError{Code: errorCodes.EmailAddressInvalid, Description: errorDesc.EmailAddressInvalid}, false
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, allowed := range strings.Fields(r.param) {
+			if value == allowed {
+				return errors.FieldError{}, true
+			}
+		}
+		return errors.FieldError{Code: errorCodes.FieldNotAllowed, Description: fmt.Sprintf(errorDesc.FieldNotAllowed, strings.Join(strings.Fields(r.param), ", "))}, false
+	}
+	return errors.FieldError{}, true
+}
+
+// Errors of the min and max rules, by rule and kind of value, their description is formatted with the limit
+var rangeErrors = map[string]map[string]errors.FieldError{
+	"min": {
+		"string": {Code: errorCodes.FieldTooShort, Description: errorDesc.FieldTooShort},
+		"items":  {Code: errorCodes.FieldTooShort, Description: errorDesc.FieldTooFewItems},
+		"number": {Code: errorCodes.FieldTooSmall, Description: errorDesc.FieldTooSmall},
+	},
+	"max": {
+		"string": {Code: errorCodes.FieldTooLong, Description: errorDesc.FieldTooLong},
+		"items":  {Code: errorCodes.FieldTooLong, Description: errorDesc.FieldTooManyItems},
+		"number": {Code: errorCodes.FieldTooLarge, Description: errorDesc.FieldTooLarge},
+	},
+}
+
+// Returns what min and max compare: the length of a string, the number of items or the value of a number
+func sizeOf(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "number"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "number"
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "number"
+	}
+	panic("validation: min and max only apply to strings, slices, maps and numbers, not " + v.Kind().String())
+}
+
+// Empty fields are the ones omitted by the client: nil, zero values and empty slices
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil() || isEmpty(v.Elem())
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,118 @@
+package validation
+
+import (
+	"goapi/errors"
+	"goapi/errors/errorCodes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testRoom struct {
+	Name    string `json:"name" validate:"required,max=5"`
+	Surface int    `json:"surface" validate:"required,min=1,max=100"`
+}
+
+type testHouse struct {
+	Name    string      `json:"name" validate:"required,min=2,max=5"`
+	Email   string      `json:"email" validate:"email"`
+	Role    string      `json:"role" validate:"oneof=editor viewer"`
+	Ratio   float64     `json:"ratio" validate:"max=1.5"`
+	Tags    []string    `json:"tags" validate:"min=2,max=3"`
+	Rooms   *[]testRoom `json:"rooms" validate:"max=2"`
+	Owner   *testRoom   `json:"owner"`
+	Ignored string      `json:"-" validate:"required"`
+	secret  string
+}
+
+// Returns the "field code" pairs of the details of a validation error, nil if there is no error
+func detailsOf(t *testing.T, err error) []string {
+	if err == nil {
+		return nil
+	}
+	apiErr, ok := err.(*errors.APIError)
+	if !ok || apiErr.Code != errors.ValidationFailed.Code {
+		t.Fatalf("error = %v, want a validation error", err)
+	}
+	var details []string
+	for _, detail := range apiErr.Details {
+		details = append(details, detail.Field+" "+detail.Code)
+	}
+	return details
+}
+
+func TestValidate(t *testing.T) {
+	rooms := func(rooms ...testRoom) *[]testRoom { return &rooms }
+	tests := []struct {
+		name string
+		body testHouse
+		want []string
+	}{
+		{"valid", testHouse{Name: "Flat"}, nil},
+		{"required empty", testHouse{}, []string{"name " + errorCodes.RequiredFieldEmpty}},
+		{"string too short", testHouse{Name: "F"}, []string{"name " + errorCodes.FieldTooShort}},
+		{"string too long", testHouse{Name: "Flat 42"}, []string{"name " + errorCodes.FieldTooLong}},
+		{"string length in runes", testHouse{Name: "été🏠é"}, nil},
+		{"too few items", testHouse{Name: "Flat", Tags: []string{"a"}}, []string{"tags " + errorCodes.FieldTooShort}},
+		{"too many items", testHouse{Name: "Flat", Tags: []string{"a", "b", "c", "d"}}, []string{"tags " + errorCodes.FieldTooLong}},
+		{"number too large", testHouse{Name: "Flat", Ratio: 1.6}, []string{"ratio " + errorCodes.FieldTooLarge}},
+		{"number at the limit", testHouse{Name: "Flat", Ratio: 1.5}, nil},
+		{"valid email", testHouse{Name: "Flat", Email: "jane@example.com"}, nil},
+		{"email with a name", testHouse{Name: "Flat", Email: "Jane <jane@example.com>"}, []string{"email " + errorCodes.EmailAddressInvalid}},
+		{"email without domain dot", testHouse{Name: "Flat", Email: "jane@localhost"}, []string{"email " + errorCodes.EmailAddressInvalid}},
+		{"email without at", testHouse{Name: "Flat", Email: "jane"}, []string{"email " + errorCodes.EmailAddressInvalid}},
+		{"oneof allowed", testHouse{Name: "Flat", Role: "viewer"}, nil},
+		{"oneof not allowed", testHouse{Name: "Flat", Role: "owner"}, []string{"role " + errorCodes.FieldNotAllowed}},
+		{"nested in slice", testHouse{Name: "Flat", Rooms: rooms(testRoom{Name: "Hall", Surface: 10}, testRoom{Name: "Kitchen", Surface: 0})},
+			[]string{"rooms[1].name " + errorCodes.FieldTooLong, "rooms[1].surface " + errorCodes.RequiredFieldEmpty}},
+		{"too many nested", testHouse{Name: "Flat", Rooms: rooms(testRoom{"A", 1}, testRoom{"B", 1}, testRoom{"C", 1})}, []string{"rooms " + errorCodes.FieldTooLong}},
+		{"nested struct", testHouse{Name: "Flat", Owner: &testRoom{Surface: 101}},
+			[]string{"owner.name " + errorCodes.RequiredFieldEmpty, "owner.surface " + errorCodes.FieldTooLarge}},
+		{"several errors", testHouse{Email: "jane", Role: "owner", Tags: []string{"a"}},
+			[]string{"name " + errorCodes.RequiredFieldEmpty, "email " + errorCodes.EmailAddressInvalid, "role " + errorCodes.FieldNotAllowed, "tags " + errorCodes.FieldTooShort}},
+	}
+	for _, test := range tests {
+		if got := detailsOf(t, Validate(test.body)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Validate details = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name string
+		body testHouse
+		want []string
+	}{
+		{"empty body", testHouse{}, nil},
+		{"only the updated fields are checked", testHouse{Role: "owner"}, []string{"role " + errorCodes.FieldNotAllowed}},
+		{"nested structs are complete", testHouse{Rooms: &[]testRoom{{Name: "Hall"}}}, []string{"rooms[0].surface " + errorCodes.RequiredFieldEmpty}},
+	}
+	for _, test := range tests {
+		if got := detailsOf(t, ValidateUpdate(&test.body)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: ValidateUpdate details = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDetailDescription(t *testing.T) {
+	err := Validate(testHouse{Name: "Flat 42", Role: "owner"}).(*errors.APIError)
+	want := []errors.FieldError{
+		{Field: "name", Code: errorCodes.FieldTooLong, Description: "must be at most 5 characters long"},
+		{Field: "role", Code: errorCodes.FieldNotAllowed, Description: "must be one of: editor, viewer"},
+	}
+	if !reflect.DeepEqual(err.Details, want) {
+		t.Errorf("details = %+v, want %+v", err.Details, want)
+	}
+}
+
+func TestUnknownRule(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "unknown rule") {
+			t.Errorf("panic = %v, want an unknown rule panic", r)
+		}
+	}()
+	_ = Validate(struct {
+		Name string `validate:"requried"`
+	}{})
+}